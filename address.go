@@ -0,0 +1,134 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Address is a postal address. Participants registered before addresses
+// were structured have a one-line address, which is kept in Line.
+type Address struct {
+	Street      string    `json:"street,omitempty"`
+	HouseNumber string    `json:"houseNumber,omitempty"`
+	PostalCode  string    `json:"postalCode,omitempty"`
+	City        string    `json:"city,omitempty"`
+	Region      string    `json:"region,omitempty"`
+	Country     string    `json:"country,omitempty"` // ISO 3166-1 alpha-2
+	Geo         *GeoPoint `json:"geo,omitempty"`
+
+	Line string `json:"line,omitempty"` // one-line address of old records
+}
+
+// UnmarshalJSON reads structured addresses as well as one-line addresses
+// of old records.
+func (a *Address) UnmarshalJSON(data []byte) error {
+	var line string
+	if err := json.Unmarshal(data, &line); err == nil {
+		*a = Address{Line: line}
+		return nil
+	}
+
+	// avoid recursion into this method
+	type address Address
+	var res address
+	if err := json.Unmarshal(data, &res); err != nil {
+		return err
+	}
+	*a = Address(res)
+	return nil
+}
+
+// String formats an address as a single line
+func (a Address) String() string {
+	if a.Line != "" {
+		return a.Line
+	}
+	parts := []string{}
+	for _, p := range []string{
+		strings.TrimSpace(a.Street + " " + a.HouseNumber),
+		strings.TrimSpace(a.PostalCode + " " + a.City),
+		a.Region,
+		a.Country,
+	} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// postal code patterns by ISO country code. Countries not listed here
+// accept any postal code.
+var postalCodePatterns = map[string]string{
+	"AT": "^[0-9]{4}$",
+	"BE": "^[0-9]{4}$",
+	"CA": "^[A-Z][0-9][A-Z] ?[0-9][A-Z][0-9]$",
+	"CH": "^[0-9]{4}$",
+	"CN": "^[0-9]{6}$",
+	"DE": "^[0-9]{5}$",
+	"DK": "^[0-9]{4}$",
+	"ES": "^[0-9]{5}$",
+	"FR": "^[0-9]{5}$",
+	"GB": "^[A-Z]{1,2}[0-9][A-Z0-9]? ?[0-9][A-Z]{2}$",
+	"IN": "^[0-9]{6}$",
+	"IT": "^[0-9]{5}$",
+	"JP": "^[0-9]{3}-[0-9]{4}$",
+	"NL": "^[0-9]{4} ?[A-Z]{2}$",
+	"PL": "^[0-9]{2}-[0-9]{3}$",
+	"SE": "^[0-9]{3} ?[0-9]{2}$",
+	"US": "^[0-9]{5}(-[0-9]{4})?$",
+}
+
+// addressSchema is the JSON schema of a structured address, to be embedded
+// into argument schemas. Postal codes are checked against the pattern of
+// the country, if there is one.
+var addressSchema = func() string {
+	countries := []string{}
+	for country := range postalCodePatterns {
+		countries = append(countries, country)
+	}
+	sort.Strings(countries)
+
+	rules := []string{}
+	for _, country := range countries {
+		rules = append(rules, fmt.Sprintf(`{
+				"anyOf": [
+					{ "properties": { "country": { "not": { "enum": [ %q ] } } } },
+					{ "properties": { "postalCode": { "pattern": %q } } }
+				]
+			}`, country, postalCodePatterns[country]))
+	}
+
+	return fmt.Sprintf(`{
+		"type": "object",
+		"properties": {
+			"street": { "type": "string", "minLength": 1, "maxLength": 100 },
+			"houseNumber": { "type": "string", "maxLength": 20 },
+			"postalCode": { "type": "string", "minLength": 1, "maxLength": 16 },
+			"city": { "type": "string", "minLength": 1, "maxLength": 100 },
+			"region": { "type": "string", "maxLength": 100 },
+			"country": {
+				"type": "string",
+				"description": "ISO 3166-1 alpha-2 country code",
+				"pattern": "^[A-Z]{2}$"
+			},
+			"geo": {
+				"type": "object",
+				"properties": {
+					"lat": { "type": "number", "minimum": -90, "maximum": 90 },
+					"lng": { "type": "number", "minimum": -180, "maximum": 180 }
+				},
+				"required": [ "lat", "lng" ]
+			}
+		},
+		"required": [ "street", "postalCode", "city", "country" ],
+		"additionalProperties": false,
+		"allOf": [
+			%s
+		]
+	}`, strings.Join(rules, ",\n\t\t\t"))
+}()
@@ -0,0 +1,468 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	sdkpeer "github.com/hyperledger/fabric-protos-go/peer"
+	"github.com/hyperledger/fabric-sdk-go/pkg/client/channel"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/retry"
+	"github.com/hyperledger/fabric-sdk-go/pkg/common/errors/status"
+	"github.com/hyperledger/fabric-sdk-go/pkg/core/config"
+	"github.com/hyperledger/fabric-sdk-go/pkg/fabsdk"
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/attrmgr"
+	"github.com/hyperledger/fabric/protos/msp"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// backend runs chaincode functions on behalf of the REST gateway and
+// the command line client.
+type backend interface {
+	// invoke submits a transaction and returns the response payload
+	invoke(function string, arg []byte) ([]byte, error)
+
+	// query evaluates a function without submitting a transaction
+	query(function string, arg []byte) ([]byte, error)
+}
+
+// chaincodeError is an error returned by the chaincode, as opposed to
+// errors reaching it.
+type chaincodeError struct {
+	message string
+}
+
+func (e chaincodeError) Error() string {
+	return e.message
+}
+
+// call submits a transaction for functions mutating state, and queries
+// all others.
+func call(b backend, function string, arg []byte) ([]byte, error) {
+	if info, found := handlers[function]; found && info.mutates {
+		return b.invoke(function, arg)
+	}
+	return b.query(function, arg)
+}
+
+// backendFlags adds flags to choose and configure a backend. The returned
+// function creates the backend after parsing.
+func backendFlags(flags *flag.FlagSet, defaultKind string) func() (backend, error) {
+	kind := flags.String("backend", defaultKind, "memory: in-memory chaincode for development, peer: Fabric network via the Fabric SDK")
+	identity := flags.String("identity", "dev", "memory backend: common name of the caller's certificate")
+	admin := flags.Bool("admin", false, "memory backend: caller is an admin")
+	stateFile := flags.String("state", "", "memory backend: file keeping the world state between runs")
+	eventsFile := flags.String("events", "", "memory backend: file to append the events of each transaction to, as JSON lines")
+	profile := flags.String("connection-profile", "", "peer backend: connection profile of the network, YAML or JSON")
+	org := flags.String("org", "", "peer backend: organization of the user, default is the client's organization in the profile")
+	user := flags.String("user", "User1", "peer backend: user of the organization whose credentials are used")
+	channelID := flags.String("channel", "mychannel", "peer backend: channel")
+	chaincode := flags.String("chaincode", "pcs", "peer backend: chaincode name")
+
+	return func() (backend, error) {
+		switch *kind {
+		case "memory":
+			b, err := newMemoryBackend(*identity, *admin, *stateFile)
+			if err != nil {
+				return nil, err
+			}
+			b.eventsFile = *eventsFile
+			return b, nil
+		case "peer":
+			return newPeerBackend(*profile, *org, *user, *channelID, *chaincode)
+		}
+		return nil, fmt.Errorf("unknown backend %s", *kind)
+	}
+}
+
+// memoryStub is the stub of an in-memory chaincode. It passes the
+// transaction's arguments itself and serves the caller's identity as
+// creator, both of which MockStub keeps to itself.
+type memoryStub struct {
+	*shim.MockStub
+	args    [][]byte
+	creator []byte
+}
+
+func (s *memoryStub) GetArgs() [][]byte {
+	return s.args
+}
+
+func (s *memoryStub) GetStringArgs() []string {
+	res := []string{}
+	for _, arg := range s.args {
+		res = append(res, string(arg))
+	}
+	return res
+}
+
+func (s *memoryStub) GetFunctionAndParameters() (string, []string) {
+	args := s.GetStringArgs()
+	if len(args) == 0 {
+		return "", []string{}
+	}
+	return args[0], args[1:]
+}
+
+func (s *memoryStub) GetArgsSlice() ([]byte, error) {
+	return bytes.Join(s.args, nil), nil
+}
+
+func (s *memoryStub) GetCreator() ([]byte, error) {
+	return s.creator, nil
+}
+
+// devCreator returns a serialized identity with a self-signed certificate
+// for the caller of an in-memory chaincode, with the given common name
+// and, for admins, the role attribute.
+func devCreator(identity string, admin bool) ([]byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: identity},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+	if admin {
+		attrs, err := json.Marshal(attrmgr.Attributes{Attrs: map[string]string{roleAttribute: roleAdmin}})
+		if err != nil {
+			return nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: attrmgr.AttrOID, Value: attrs}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&msp.SerializedIdentity{
+		Mspid:   "DevMSP",
+		IdBytes: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	})
+}
+
+// memoryBackend runs the chaincode in-process on a MockStub, one call at
+// a time. Like on a peer, failed transactions and queries leave no changes.
+type memoryBackend struct {
+	mu   sync.Mutex
+	cc   shim.Chaincode
+	stub *memoryStub
+
+	// if set, the world state is loaded from and saved to this file
+	stateFile string
+
+	// if set, the events of each transaction are appended to this file
+	eventsFile string
+}
+
+// eventRecord is a line of an events file, holding the events of a
+// transaction. The off-chain indexer reads these.
+type eventRecord struct {
+	TxID      string           `json:"txId"`
+	Timestamp time.Time        `json:"timestamp"`
+	Events    []chaincodeEvent `json:"events"`
+}
+
+func newMemoryBackend(identity string, admin bool, stateFile string) (*memoryBackend, error) {
+	creator, err := devCreator(identity, admin)
+	if err != nil {
+		return nil, err
+	}
+	cc := &PreciousCargoChaincode{handlers: handlers}
+	b := &memoryBackend{
+		cc:        cc,
+		stub:      &memoryStub{MockStub: shim.NewMockStub(ns, cc), creator: creator},
+		stateFile: stateFile,
+	}
+	res := b.call(b.nextTxID(), [][]byte{[]byte("init")}, b.cc.Init)
+	if res.Status != shim.OK {
+		return nil, fmt.Errorf("init failed: %s", res.Message)
+	}
+	if err := b.loadState(); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// loadState reads the state file, if it exists
+func (b *memoryBackend) loadState() error {
+	if b.stateFile == "" {
+		return nil
+	}
+	data, err := ioutil.ReadFile(b.stateFile)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	state := map[string][]byte{}
+	if err = json.Unmarshal(data, &state); err != nil {
+		return fmt.Errorf("invalid state file %s: %s", b.stateFile, err)
+	}
+
+	keys := []string{}
+	for k := range state {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	b.stub.State = state
+	b.stub.Keys = list.New()
+	for _, k := range keys {
+		b.stub.Keys.PushBack(k)
+	}
+	return nil
+}
+
+// saveState writes the state file, if there is one
+func (b *memoryBackend) saveState() error {
+	if b.stateFile == "" {
+		return nil
+	}
+	data, err := json.Marshal(b.stub.State)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(b.stateFile, data, 0600)
+}
+
+// nextTxID returns a new transaction ID. IDs must be unique across runs
+// sharing a state file, as consumers of the events rely on them.
+func (b *memoryBackend) nextTxID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		logger.Error(err)
+	}
+	return fmt.Sprintf("%x", id)
+}
+
+func (b *memoryBackend) invoke(function string, arg []byte) ([]byte, error) {
+	return b.run(function, arg, true)
+}
+
+func (b *memoryBackend) query(function string, arg []byte) ([]byte, error) {
+	return b.run(function, arg, false)
+}
+
+func (b *memoryBackend) run(function string, arg []byte, commit bool) ([]byte, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	// MockStub writes through, so keep the state to roll back to
+	state := map[string][]byte{}
+	for k, v := range b.stub.State {
+		state[k] = v
+	}
+	keys := list.New()
+	keys.PushBackList(b.stub.Keys)
+
+	txID := b.nextTxID()
+	res := b.call(txID, [][]byte{[]byte(function), arg}, b.cc.Invoke)
+	events := b.drainEvents()
+
+	if res.Status != shim.OK || !commit {
+		b.stub.State, b.stub.Keys = state, keys
+	}
+	if res.Status != shim.OK {
+		return nil, chaincodeError{message: res.Message}
+	}
+	if commit {
+		if err := b.appendEvents(txID, events); err != nil {
+			b.stub.State, b.stub.Keys = state, keys
+			return nil, err
+		}
+		if err := b.saveState(); err != nil {
+			return nil, err
+		}
+	}
+	return res.Payload, nil
+}
+
+// call runs Init or Invoke of the chaincode in a transaction, like
+// MockInit and MockInvoke do, but on the memoryStub
+func (b *memoryBackend) call(txID string, args [][]byte, fn func(shim.ChaincodeStubInterface) pb.Response) pb.Response {
+	b.stub.args = args
+	b.stub.MockTransactionStart(txID)
+	defer b.stub.MockTransactionEnd(txID)
+	return fn(b.stub)
+}
+
+// appendEvents writes the events of a transaction to the events file,
+// if there is one and there are events.
+func (b *memoryBackend) appendEvents(txID string, events []chaincodeEvent) error {
+	if b.eventsFile == "" || len(events) == 0 {
+		return nil
+	}
+	at, err := txTime(b.stub)
+	if err != nil {
+		return err
+	}
+	line, err := json.Marshal(eventRecord{TxID: txID, Timestamp: at, Events: events})
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(b.eventsFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// drainEvents empties the MockStub's event channel, which would block
+// the chaincode once full.
+func (b *memoryBackend) drainEvents() []chaincodeEvent {
+	res := []chaincodeEvent{}
+	for {
+		select {
+		case ev := <-b.stub.ChaincodeEventsChannel:
+			events := []chaincodeEvent{}
+			if err := json.Unmarshal(ev.Payload, &events); err != nil {
+				logger.Warn(err)
+				continue
+			}
+			res = append(res, events...)
+		default:
+			return res
+		}
+	}
+}
+
+// peerBackend calls the chaincode on a Fabric network through the channel
+// client of the Fabric SDK, configured by a connection profile.
+type peerBackend struct {
+	client    *channel.Client
+	chaincode string
+}
+
+func newPeerBackend(profile, org, user, channelID, chaincode string) (*peerBackend, error) {
+	if profile == "" {
+		return nil, errors.New("peer backend needs a connection profile")
+	}
+	sdk, err := fabsdk.New(config.FromFile(profile))
+	if err != nil {
+		return nil, fmt.Errorf("unable to set up Fabric SDK: %s", err)
+	}
+	options := []fabsdk.ContextOption{fabsdk.WithUser(user)}
+	if org != "" {
+		options = append(options, fabsdk.WithOrg(org))
+	}
+	client, err := channel.New(sdk.ChannelContext(channelID, options...))
+	if err != nil {
+		sdk.Close()
+		return nil, fmt.Errorf("unable to connect to channel %s: %s", channelID, err)
+	}
+	return &peerBackend{client: client, chaincode: chaincode}, nil
+}
+
+// invoke has the transaction endorsed, submits it to the orderer and
+// waits until it is committed. It fails unless all endorsers succeeded
+// with the same payload and the transaction was committed as valid.
+func (b *peerBackend) invoke(function string, arg []byte) ([]byte, error) {
+	res, err := b.client.Execute(b.request(function, arg), channel.WithRetry(retry.DefaultChannelOpts))
+	if err != nil {
+		return nil, peerError(err)
+	}
+	if err = checkEndorsements(res); err != nil {
+		return nil, err
+	}
+	if res.TxValidationCode != sdkpeer.TxValidationCode_VALID {
+		return nil, fmt.Errorf("transaction %s was not committed: %s", res.TransactionID, res.TxValidationCode)
+	}
+	return res.Payload, nil
+}
+
+// query evaluates the function on endorsers, without submitting a
+// transaction
+func (b *peerBackend) query(function string, arg []byte) ([]byte, error) {
+	res, err := b.client.Query(b.request(function, arg), channel.WithRetry(retry.DefaultChannelOpts))
+	if err != nil {
+		return nil, peerError(err)
+	}
+	if err = checkEndorsements(res); err != nil {
+		return nil, err
+	}
+	return res.Payload, nil
+}
+
+func (b *peerBackend) request(function string, arg []byte) channel.Request {
+	return channel.Request{
+		ChaincodeID: b.chaincode,
+		Fcn:         function,
+		Args:        [][]byte{arg},
+	}
+}
+
+// checkEndorsements makes sure that there are endorsements, that the
+// chaincode succeeded on all endorsers and that they agree on the payload
+func checkEndorsements(res channel.Response) error {
+	if len(res.Responses) == 0 {
+		return errors.New("no endorsements received")
+	}
+	for _, r := range res.Responses {
+		if r.ChaincodeStatus >= shim.ERRORTHRESHOLD {
+			return chaincodeError{message: r.Response.GetMessage()}
+		}
+		if r.Status != shim.OK {
+			return fmt.Errorf("endorsement of %s failed: %d %s", r.Endorser, r.Status, r.Response.GetMessage())
+		}
+		if !bytes.Equal(r.Response.GetPayload(), res.Payload) {
+			return fmt.Errorf("endorsement of %s differs from the others", r.Endorser)
+		}
+	}
+	return nil
+}
+
+// peerError tells chaincode errors, which endorsers report with the
+// chaincode's message, from errors reaching the chaincode
+func peerError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	switch s.Group {
+	case status.ChaincodeStatus, status.EndorserServerStatus:
+		if s.Code >= shim.ERRORTHRESHOLD {
+			return chaincodeError{message: s.Message}
+		}
+	}
+	// errors of several endorsers come as details
+	for _, detail := range s.Details {
+		if e, ok := detail.(error); ok {
+			if ce, ok := peerError(e).(chaincodeError); ok {
+				return ce
+			}
+		}
+	}
+	return err
+}
+
+// compile time check of the backends
+var (
+	_ backend = &memoryBackend{}
+	_ backend = &peerBackend{}
+)
@@ -0,0 +1,50 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import "testing"
+
+func TestMemoryBackendIdentity(t *testing.T) {
+	tests := []struct {
+		identity string
+		admin    bool
+	}{
+		{identity: "admin", admin: true},
+		{identity: "alice", admin: false},
+	}
+	for _, tt := range tests {
+		b, err := newMemoryBackend(tt.identity, tt.admin, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		id, err := callerID(b.stub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		admin := callerIsAdmin(b.stub)
+
+		other, err := newMemoryBackend(tt.identity+"2", tt.admin, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		otherID, err := callerID(other.stub)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if id == "" || id == otherID {
+			t.Errorf("%s: expected an ID of its own, got %q", tt.identity, id)
+		}
+		if admin != tt.admin {
+			t.Errorf("%s: expected admin %v, got %v", tt.identity, tt.admin, admin)
+		}
+
+		// only admins may change the configuration
+		_, err = b.invoke("setConfig", []byte(`{"logLevel":"info"}`))
+		if tt.admin && err != nil {
+			t.Errorf("%s: unexpected error: %s", tt.identity, err)
+		}
+		if !tt.admin && (err == nil || err.Error() != errNotAuthorized.Error()) {
+			t.Errorf("%s: expected %s, got %v", tt.identity, errNotAuthorized, err)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	breakSealSchema = `
+{
+	"$id": "PreciousCargoShippping:breakSealSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Container"
+		},
+		"seal": {
+			"type": "string",
+			"description": "number of the seal to break"
+		},
+		"remarks": {
+			"type": "string",
+			"description": "reason, e.g. customs inspection",
+			"maxLength": 500
+		}
+	},
+	"required": [ "id", "seal" ]
+}
+`
+	breakSealSchemaLoader = gojsonschema.NewStringLoader(breakSealSchema)
+)
+
+// Records that a seal has been broken on purpose, e.g. to unload or
+// inspect a container.
+type breakSealArg struct {
+	ID      string `json:"id"`
+	Seal    string `json:"seal"`
+	Remarks string `json:"remarks"`
+}
+
+type breakSealInvocation struct {
+	eventRecorder
+
+	arg breakSealArg
+
+	container *Container
+	seal      *Seal
+
+	res SealCheck
+}
+
+func (inv *breakSealInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter breakSealInvocation.checkParseArguments")
+
+	inv.arg = breakSealArg{}
+	err := parseArgument(stub, breakSealSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.container, err = getContainer(stub, inv.arg.ID)
+	if err != nil {
+		return err
+	}
+	if err = checkCallerHandlesContainer(stub, inv.container); err != nil {
+		return err
+	}
+	inv.seal, err = inv.container.seal(inv.arg.Seal)
+	if err != nil {
+		return fmt.Errorf("invalid seal argument: %s", err)
+	}
+	if inv.seal.Status != SealIntact {
+		return errors.New("invalid seal argument: Seal is broken already")
+	}
+	return nil
+}
+
+func (inv *breakSealInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter breakSealInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	by, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+
+	inv.seal.Status = SealBroken
+	inv.seal.BrokenAt = &now
+	if err = containerRegistry().update(stub, inv.container.ID.ID, inv.container); err != nil {
+		return err
+	}
+
+	inv.res = SealCheck{
+		ContainerID: inv.container.ID.ID,
+		SealNumber:  inv.seal.Number,
+		Action:      SealActionBroken,
+		By:          by,
+		At:          now,
+		Remarks:     inv.arg.Remarks,
+	}
+	if err = putSealCheck(stub, inv.res); err != nil {
+		return err
+	}
+	inv.emit(sealCheckType, inv.res)
+
+	return nil
+}
+
+func (inv *breakSealInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,261 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maximum number of participants accepted by bulkRegisterParticipants
+const maxParticipantBatchSize = 100
+
+var (
+	bulkRegisterParticipantsSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:bulkRegisterParticipantsSchema",
+	"type": "object",
+	"properties": {
+		"participants": {
+			"type": "array",
+			"minItems": 1,
+			"maxItems": %d,
+			"items": {
+				"type": "object",
+				"description": "argument of the register function of the kind, checked per participant",
+				"properties": {
+					"kind": {
+						"type": "string",
+						"enum": [ "%s", "%s", "%s" ]
+					}
+				},
+				"required": [ "kind" ]
+			}
+		}
+	},
+	"required": [ "participants" ]
+}
+`, maxParticipantBatchSize, KindIndividualParticipant, KindCorporateParticipant, KindShipmentCo)
+	bulkRegisterParticipantsSchemaLoader = gojsonschema.NewStringLoader(bulkRegisterParticipantsSchema)
+)
+
+// schemas of a single participant in a batch, by kind
+var participantRowSchemaLoaders = map[string]gojsonschema.JSONLoader{
+	KindIndividualParticipant: registerIndividualParticipantSchemaLoader,
+	KindCorporateParticipant:  registerCorporateParticipantSchemaLoader,
+	KindShipmentCo:            registerShipmentCoSchemaLoader,
+}
+
+// Registers participants of any kind, each given like the argument of
+// its register function plus its kind. Every participant is checked on
+// its own, invalid ones are reported but do not fail the others.
+type bulkRegisterParticipantsArg struct {
+	Participants []json.RawMessage `json:"participants"`
+}
+
+// Registration status of a single participant, by its index in the batch
+type bulkRegisterParticipantsItemResult struct {
+	Index      int      `json:"index"`
+	Kind       string   `json:"kind,omitempty"`
+	ID         string   `json:"id,omitempty"`
+	Duplicates []string `json:"duplicates,omitempty"` // if registered anyway
+	Error      string   `json:"error,omitempty"`
+}
+
+// Returns number of registered and rejected participants and status per item
+type bulkRegisterParticipantsResult struct {
+	Registered int                                  `json:"registered"`
+	Rejected   int                                  `json:"rejected"`
+	Items      []bulkRegisterParticipantsItemResult `json:"items"`
+}
+
+// a participant of the batch which passed all checks
+type bulkParticipant struct {
+	index int
+	kind  string
+	x     interface{}
+
+	// earlier participants of the batch this one duplicates
+	duplicateOf []int
+}
+
+type bulkRegisterParticipantsInvocation struct {
+	eventRecorder
+
+	arg bulkRegisterParticipantsArg
+	res bulkRegisterParticipantsResult
+}
+
+func (inv *bulkRegisterParticipantsInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter bulkRegisterParticipantsInvocation.checkParseArguments")
+
+	inv.arg = bulkRegisterParticipantsArg{}
+	return parseArgument(stub, bulkRegisterParticipantsSchemaLoader, &inv.arg)
+}
+
+// process checks all participants first, then registers the valid ones
+// with IDs taken at once.
+func (inv *bulkRegisterParticipantsInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter bulkRegisterParticipantsInvocation.process")
+	// participants are raw JSON, which would not be redacted
+	loggerFor(stub).Debugf("%d participants", len(inv.arg.Participants))
+
+	identity, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+	admin := callerIsAdmin(stub)
+
+	inv.res = bulkRegisterParticipantsResult{
+		Items: make([]bulkRegisterParticipantsItemResult, len(inv.arg.Participants)),
+	}
+
+	// the fingerprint index does not show participants of this
+	// transaction, so duplicates within the batch are tracked here
+	fingerprints := map[string]int{}
+	accepted := []*bulkParticipant{}
+	for idx, row := range inv.arg.Participants {
+		item := &inv.res.Items[idx]
+		item.Index = idx
+
+		p, duplicates, err := checkBulkParticipant(stub, row, identity, admin, fingerprints, idx)
+		if err != nil {
+			item.Error = err.Error()
+			inv.res.Rejected++
+			continue
+		}
+		item.Kind = p.kind
+		item.Duplicates = duplicates
+		for _, fp := range participantFingerprints(p.x) {
+			if _, found := fingerprints[p.kind+fp]; !found {
+				fingerprints[p.kind+fp] = idx
+			}
+		}
+		accepted = append(accepted, p)
+	}
+	if len(accepted) == 0 {
+		return nil
+	}
+
+	ids, err := newParticipantIDs(stub, len(accepted))
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error generating index key")
+	}
+	for i, p := range accepted {
+		participantOf(p.x).ID.ID = ids[i]
+		inv.res.Items[p.index].ID = ids[i]
+	}
+	for _, p := range accepted {
+		item := &inv.res.Items[p.index]
+		for _, other := range p.duplicateOf {
+			item.Duplicates = append(item.Duplicates, inv.res.Items[other].ID)
+		}
+
+		if err = participantRegistries(p.kind)[0].update(stub, item.ID, p.x); err != nil {
+			return err
+		}
+		if err = indexParticipant(stub, p.kind, p.x); err != nil {
+			return err
+		}
+		inv.emit("ParticipantSaved", participantSavedEvent{Kind: p.kind, Participant: p.x})
+		inv.res.Registered++
+	}
+
+	return nil
+}
+
+// checkBulkParticipant validates a participant of a batch and checks it
+// for duplicates among registered participants and earlier ones of the
+// batch, given by fingerprints. Returns the participant and the IDs of
+// registered duplicates, if duplicates are allowed.
+func checkBulkParticipant(stub shim.ChaincodeStubInterface, row json.RawMessage, identity string, admin bool, fingerprints map[string]int, idx int) (*bulkParticipant, []string, error) {
+	var head struct {
+		Kind string `json:"kind"`
+	}
+	if err := json.Unmarshal(row, &head); err != nil {
+		return nil, nil, errors.New("Invalid JSON")
+	}
+	loader, found := participantRowSchemaLoaders[head.Kind]
+	if !found {
+		return nil, nil, fmt.Errorf("invalid kind %s", head.Kind)
+	}
+	if err := validateJSON(loader, row); err != nil {
+		return nil, nil, err
+	}
+
+	p, allowDuplicate, err := newBulkParticipant(head.Kind, row, identity)
+	if err != nil {
+		return nil, nil, err
+	}
+	p.index = idx
+	// only admins may register duplicates
+	if allowDuplicate && !admin {
+		return nil, nil, errNotAuthorized
+	}
+
+	duplicates, err := checkDuplicateParticipants(stub, p.kind, p.x, allowDuplicate)
+	if err != nil {
+		return nil, nil, err
+	}
+	seen := map[int]bool{}
+	for _, fp := range participantFingerprints(p.x) {
+		other, found := fingerprints[p.kind+fp]
+		if !found || seen[other] {
+			continue
+		}
+		if !allowDuplicate {
+			return nil, nil, fmt.Errorf("duplicate of participant %d of this batch, set allowDuplicate to register anyway", other)
+		}
+		seen[other] = true
+		p.duplicateOf = append(p.duplicateOf, other)
+	}
+	return p, duplicates, nil
+}
+
+// newBulkParticipant creates a participant of the given kind from its
+// validated JSON. Returns whether duplicates are allowed.
+func newBulkParticipant(kind string, row json.RawMessage, identity string) (*bulkParticipant, bool, error) {
+	p := Participant{
+		Identity: identity,
+		Status:   ParticipantActive,
+	}
+	switch kind {
+	case KindIndividualParticipant:
+		var arg registerIndividualParticipantArg
+		if err := json.Unmarshal(row, &arg); err != nil {
+			return nil, false, errors.New("Invalid JSON")
+		}
+		p.Name = arg.Name
+		return &bulkParticipant{kind: kind, x: &IndividualParticipant{Participant: p, Address: arg.Address}}, arg.AllowDuplicate, nil
+	case KindCorporateParticipant:
+		var arg registerCorporateParticipantArg
+		if err := json.Unmarshal(row, &arg); err != nil {
+			return nil, false, errors.New("Invalid JSON")
+		}
+		p.Name = arg.Name
+		return &bulkParticipant{kind: kind, x: &CorporateParticipant{
+			Participant:        p,
+			Address:            arg.Address,
+			RegistrationNumber: arg.RegistrationNumber,
+			VATID:              arg.VATID,
+			Contacts:           arg.Contacts,
+		}}, arg.AllowDuplicate, nil
+	case KindShipmentCo:
+		var arg registerShipmentCoArg
+		if err := json.Unmarshal(row, &arg); err != nil {
+			return nil, false, errors.New("Invalid JSON")
+		}
+		p.Name = arg.Name
+		return &bulkParticipant{kind: kind, x: &ShipmentCo{Participant: p, Address: arg.Address}}, arg.AllowDuplicate, nil
+	}
+	return nil, false, fmt.Errorf("invalid kind %s", kind)
+}
+
+func (inv *bulkRegisterParticipantsInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
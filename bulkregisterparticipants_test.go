@@ -0,0 +1,63 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestBulkRegisterParticipantsLogsNoPII(t *testing.T) {
+	// log everything into a buffer
+	var buf bytes.Buffer
+	out, level, fixedLevel := logger.out.w, logger.level, logger.out.fixedLevel
+	logger.SetOutput(&buf)
+	logger.SetLevel(levelDebug)
+	defer func() {
+		logger.SetOutput(out)
+		logger.level, logger.out.fixedLevel = level, fixedLevel
+	}()
+
+	b, err := newMemoryBackend("admin", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pii := []string{"Wilhelmina Geheim", "Verstecktweg", "Klandestina GmbH", "Heimlichallee", "Diskretstadt"}
+	arg, err := json.Marshal(map[string]interface{}{
+		"participants": []map[string]interface{}{
+			{
+				"kind":    KindIndividualParticipant,
+				"name":    "Wilhelmina Geheim",
+				"address": Address{Street: "Verstecktweg", HouseNumber: "7", PostalCode: "10115", City: "Diskretstadt", Country: "DE"},
+			},
+			{
+				"kind":    KindCorporateParticipant,
+				"name":    "Klandestina GmbH",
+				"address": Address{Street: "Heimlichallee", HouseNumber: "1", PostalCode: "10115", City: "Diskretstadt", Country: "DE"},
+			},
+			{
+				// invalid, reported with the row
+				"kind": KindIndividualParticipant,
+				"name": "Wilhelmina Geheim",
+			},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = b.invoke("bulkRegisterParticipants", arg); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := buf.String()
+	if !strings.Contains(lines, "3 participants") {
+		t.Fatalf("expected the number of participants to be logged, got\n%s", lines)
+	}
+	for _, s := range pii {
+		if strings.Contains(lines, s) {
+			t.Errorf("%q was logged:\n%s", s, lines)
+		}
+	}
+}
@@ -0,0 +1,414 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// Client commands build a function's argument from flags, validate it
+// against the function's schema and call it, on a Fabric network by
+// default or on an in-memory chaincode with -dry-run.
+
+// stringList is a flag which may be given more than once
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+// client holds the flags all client commands share
+type client struct {
+	flags      *flag.FlagSet
+	newBackend func() (backend, error)
+	dryRun     *bool
+	verbose    *bool
+}
+
+func newClient(name string) *client {
+	c := &client{
+		flags: flag.NewFlagSet(name, flag.ContinueOnError),
+	}
+	c.newBackend = backendFlags(c.flags, "peer")
+	c.dryRun = c.flags.Bool("dry-run", false, "call an in-memory chaincode instead, same as -backend memory")
+	c.verbose = c.flags.Bool("v", false, "log what the in-memory chaincode does")
+	return c
+}
+
+func (c *client) parse(args []string) error {
+	if err := c.flags.Parse(args); err != nil {
+		return err
+	}
+	if *c.verbose {
+		logger.SetLevel(levelDebug)
+	} else {
+		logger.SetOutput(ioutil.Discard)
+	}
+	if *c.dryRun {
+		return c.flags.Set("backend", "memory")
+	}
+	return nil
+}
+
+// validateArgument checks an argument against the function's schema,
+// before sending it anywhere.
+func validateArgument(function string, arg []byte) error {
+	if function == describeFunction {
+		return nil
+	}
+	info, found := handlers[function]
+	if !found {
+		return fmt.Errorf("unknown function %s", function)
+	}
+	if info.schema == "" {
+		return nil
+	}
+	result, err := gojsonschema.Validate(gojsonschema.NewStringLoader(info.schema), gojsonschema.NewBytesLoader(arg))
+	if err != nil {
+		return fmt.Errorf("invalid argument: %s", err)
+	}
+	if !result.Valid() {
+		msgs := []string{}
+		for _, e := range result.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return fmt.Errorf("invalid argument:\n  %s", strings.Join(msgs, "\n  "))
+	}
+	return nil
+}
+
+// call validates and calls a function, and pretty-prints its response
+func (c *client) call(function string, arg interface{}) error {
+	data, err := json.Marshal(arg)
+	if err != nil {
+		return err
+	}
+	if err = validateArgument(function, data); err != nil {
+		return err
+	}
+	b, err := c.newBackend()
+	if err != nil {
+		return err
+	}
+	payload, err := call(b, function, data)
+	if err != nil {
+		return err
+	}
+
+	var out bytes.Buffer
+	if json.Indent(&out, payload, "", "  ") != nil {
+		// not JSON, print as is
+		out.Reset()
+		out.Write(payload)
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
+
+// readJSONFile decodes a JSON file into v
+func readJSONFile(name string, v interface{}) error {
+	data, err := ioutil.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("%s: %s", name, err)
+	}
+	return nil
+}
+
+// splitFields splits a comma separated flag value into n fields, of which
+// the first required are required.
+func splitFields(v string, n, required int, usage string) ([]string, error) {
+	fields := strings.Split(v, ",")
+	if len(fields) < required || len(fields) > n {
+		return nil, fmt.Errorf("invalid value %q, expecting %s", v, usage)
+	}
+	for len(fields) < n {
+		fields = append(fields, "")
+	}
+	for i := range fields {
+		fields[i] = strings.TrimSpace(fields[i])
+	}
+	return fields, nil
+}
+
+var participantKindFunctions = map[string]string{
+	"individual": "registerIndividualParticipant",
+	"corporate":  "registerCorporateParticipant",
+	"shipmentco": "registerShipmentCo",
+}
+
+// registerParticipantCommand registers a participant of any kind
+func registerParticipantCommand(args []string) error {
+	c := newClient("register-participant")
+	kind := c.flags.String("kind", "individual", "individual, corporate or shipmentco")
+	name := c.flags.String("name", "", "name of the participant")
+	street := c.flags.String("street", "", "address: street")
+	houseNumber := c.flags.String("house-number", "", "address: house number")
+	postalCode := c.flags.String("postal-code", "", "address: postal code")
+	city := c.flags.String("city", "", "address: city")
+	region := c.flags.String("region", "", "address: region")
+	country := c.flags.String("country", "", "address: ISO 3166-1 alpha-2 country code")
+	geo := c.flags.String("geo", "", "address: coordinates as LAT,LNG")
+	registrationNumber := c.flags.String("registration-number", "", "corporate: number in company register")
+	vatID := c.flags.String("vat-id", "", "corporate: VAT identification number")
+	var contacts stringList
+	c.flags.Var(&contacts, "contact", "corporate: contact person as NAME,EMAIL[,PHONE[,ROLE]], may be repeated")
+	allowDuplicate := c.flags.Bool("allow-duplicate", false, "register even if the participant seems to exist already (admins only)")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	function, found := participantKindFunctions[*kind]
+	if !found {
+		return fmt.Errorf("unknown kind %s", *kind)
+	}
+
+	address := map[string]interface{}{
+		"street":     *street,
+		"postalCode": *postalCode,
+		"city":       *city,
+		"country":    strings.ToUpper(*country),
+	}
+	if *houseNumber != "" {
+		address["houseNumber"] = *houseNumber
+	}
+	if *region != "" {
+		address["region"] = *region
+	}
+	if *geo != "" {
+		fields, err := splitFields(*geo, 2, 2, "LAT,LNG")
+		if err != nil {
+			return err
+		}
+		lat, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fmt.Errorf("invalid latitude %s", fields[0])
+		}
+		lng, err := strconv.ParseFloat(fields[1], 64)
+		if err != nil {
+			return fmt.Errorf("invalid longitude %s", fields[1])
+		}
+		address["geo"] = GeoPoint{Latitude: lat, Longitude: lng}
+	}
+
+	arg := map[string]interface{}{
+		"name":    *name,
+		"address": address,
+	}
+	if *allowDuplicate {
+		arg["allowDuplicate"] = true
+	}
+	if *kind == "corporate" {
+		arg["registrationNumber"] = *registrationNumber
+		if *vatID != "" {
+			arg["vatId"] = *vatID
+		}
+		persons := []ContactPerson{}
+		for _, contact := range contacts {
+			fields, err := splitFields(contact, 4, 2, "NAME,EMAIL[,PHONE[,ROLE]]")
+			if err != nil {
+				return err
+			}
+			persons = append(persons, ContactPerson{Name: fields[0], Email: fields[1], Phone: fields[2], Role: fields[3]})
+		}
+		if len(persons) > 0 {
+			arg["contacts"] = persons
+		}
+	}
+
+	return c.call(function, arg)
+}
+
+// submitCommand submits a shipment
+func submitCommand(args []string) error {
+	c := newClient("submit")
+	arg := submitShipmentArg{}
+	c.flags.StringVar(&arg.Shipper, "by", "", "ID of the shipper")
+	c.flags.StringVar(&arg.From, "from", "", "ID of the sender")
+	c.flags.StringVar(&arg.To, "to", "", "ID of the recipient")
+	c.flags.StringVar(&arg.SubmittedAt, "submitted-at", time.Now().UTC().Format(time.RFC3339), "time of submission in RFC3339")
+	var legs, devices, items stringList
+	c.flags.Var(&legs, "leg", "leg as CARRIER,ORIGIN,DESTINATION,DEPARTURE,ARRIVAL with times in RFC3339, may be repeated")
+	c.flags.Var(&devices, "device", "ID of a tracking device, may be repeated")
+	c.flags.Var(&items, "item", "manifest item as SKU,QUANTITY[,DESCRIPTION], may be repeated")
+	routeFile := c.flags.String("route", "", "JSON file with the planned route")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+
+	for _, leg := range legs {
+		fields, err := splitFields(leg, 5, 5, "CARRIER,ORIGIN,DESTINATION,DEPARTURE,ARRIVAL")
+		if err != nil {
+			return err
+		}
+		arg.Legs = append(arg.Legs, submitShipmentLegArg{
+			Carrier:          fields[0],
+			OriginHub:        fields[1],
+			DestinationHub:   fields[2],
+			PlannedDeparture: fields[3],
+			PlannedArrival:   fields[4],
+		})
+	}
+	arg.Devices = devices
+	for _, item := range items {
+		fields, err := splitFields(item, 3, 2, "SKU,QUANTITY[,DESCRIPTION]")
+		if err != nil {
+			return err
+		}
+		quantity, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return fmt.Errorf("invalid quantity %s", fields[1])
+		}
+		arg.Manifest = append(arg.Manifest, ManifestItem{SKU: fields[0], Quantity: quantity, Description: fields[2]})
+	}
+	if *routeFile != "" {
+		arg.Route = &PlannedRoute{}
+		if err := readJSONFile(*routeFile, arg.Route); err != nil {
+			return err
+		}
+	}
+
+	return c.call("submitShipment", arg)
+}
+
+// trackCommand records a reading, or a batch of readings from a file
+func trackCommand(args []string) error {
+	c := newClient("track")
+	id := c.flags.String("id", "", "ID of the shipment")
+	reading := trackingReadingArg{}
+	c.flags.StringVar(&reading.Device, "device", "", "ID of the tracking device")
+	c.flags.StringVar(&reading.At, "at", time.Now().UTC().Format(time.RFC3339), "time of the reading in RFC3339")
+	c.flags.Float64Var(&reading.Latitude, "lat", 0, "latitude")
+	c.flags.Float64Var(&reading.Longitude, "lng", 0, "longitude")
+	temp := c.flags.Float64("temp", 0, "temperature")
+	hum := c.flags.Float64("hum", 0, "humidity in %")
+	c.flags.Uint64Var(&reading.Counter, "counter", 0, "counter of the device, must increase with each reading")
+	c.flags.StringVar(&reading.Signature, "sig", "", "signature of the device in base64")
+	keyFile := c.flags.String("key", "", "PEM file with the device's private key, to sign readings with")
+	batchFile := c.flags.String("batch", "", "JSON file with an array of readings, sent as one batch")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	reading.Temperature = float32(*temp)
+	reading.Humidity = float32(*hum)
+
+	readings := []trackingReadingArg{reading}
+	if *batchFile != "" {
+		readings = nil
+		if err := readJSONFile(*batchFile, &readings); err != nil {
+			return err
+		}
+	}
+
+	if *keyFile != "" {
+		data, err := ioutil.ReadFile(*keyFile)
+		if err != nil {
+			return err
+		}
+		key, err := parseDevicePrivateKey(string(data))
+		if err != nil {
+			return err
+		}
+		for i := range readings {
+			tdp, err := readings[i].toDataPoint(*id)
+			if err != nil {
+				return fmt.Errorf("reading %d: %s", i, err)
+			}
+			if readings[i].Signature, err = signTrackingDataPoint(key, tdp); err != nil {
+				return err
+			}
+		}
+	}
+
+	if *batchFile != "" {
+		return c.call("trackShipmentBatch", map[string]interface{}{
+			"id":       *id,
+			"readings": readings,
+		})
+	}
+	return c.call("trackShipment", trackShipmentArg{ID: *id, trackingReadingArg: readings[0]})
+}
+
+// showTargets maps what show can show to functions
+var showTargets = map[string]string{
+	"shipment":    "getShipment",
+	"participant": "getParticipant",
+	"container":   "getContainer",
+	"tracking":    "getTrackingData",
+	"summary":     "getTrackingSummary",
+	"events":      "getRouteEvents",
+	"geojson":     "getShipmentTrackGeoJSON",
+}
+
+// showCommand shows a shipment, participant or the like by ID
+func showCommand(args []string) error {
+	c := newClient("show")
+	kind := c.flags.String("kind", "", "participant: kind of participant, if the ID is ambiguous")
+	from := c.flags.String("from", "", "tracking: start of time range in RFC3339")
+	to := c.flags.String("to", "", "tracking: end of time range in RFC3339")
+	if len(args) < 2 || strings.HasPrefix(args[0], "-") {
+		targets := []string{}
+		for t := range showTargets {
+			targets = append(targets, t)
+		}
+		sort.Strings(targets)
+		return fmt.Errorf("usage: show %s ID [FLAGS]", strings.Join(targets, "|"))
+	}
+	target, id := args[0], args[1]
+	if err := c.parse(args[2:]); err != nil {
+		return err
+	}
+
+	function, found := showTargets[target]
+	if !found {
+		return fmt.Errorf("unable to show %s", target)
+	}
+	arg := map[string]interface{}{"id": id}
+	if *kind != "" {
+		arg["kind"] = *kind
+	}
+	if *from != "" {
+		arg["from"] = *from
+	}
+	if *to != "" {
+		arg["to"] = *to
+	}
+	return c.call(function, arg)
+}
+
+// callCommand calls any function with a JSON argument
+func callCommand(args []string) error {
+	c := newClient("call")
+	if len(args) < 1 || strings.HasPrefix(args[0], "-") {
+		return errors.New("usage: call FUNCTION [JSON] [FLAGS]")
+	}
+	function, arg := args[0], "{}"
+	args = args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		arg, args = args[0], args[1:]
+	}
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	return c.call(function, json.RawMessage(arg))
+}
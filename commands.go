@@ -0,0 +1,82 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+)
+
+// command is run by the chaincode binary instead of the chaincode, if its
+// name is the first argument, e.g. "pcs openapi -o api.json".
+type command struct {
+	usage string
+	run   func(args []string) error
+}
+
+var commands map[string]command
+
+func init() {
+	// set in init, since help refers to commands
+	commands = map[string]command{
+		"help": {
+			usage: "lists commands",
+			run:   helpCommand,
+		},
+		"register-participant": {
+			usage: "registers a participant -kind individual|corporate|shipmentco -name NAME -street ... [-dry-run]",
+			run:   registerParticipantCommand,
+		},
+		"submit": {
+			usage: "submits a shipment -by ID -from ID -to ID [-leg ...] [-device ID] [-item ...] [-dry-run]",
+			run:   submitCommand,
+		},
+		"track": {
+			usage: "records a reading -id ID -device ID -lat LAT -lng LNG -counter N [-key PEM | -sig SIG] [-batch FILE] [-dry-run]",
+			run:   trackCommand,
+		},
+		"csv2batch": {
+			usage: "converts a CSV file of participants into bulkRegisterParticipants arguments [-i FILE] [-o FILE] [-size N]",
+			run:   csv2batchCommand,
+		},
+		"show": {
+			usage: "shows shipment|participant|container|tracking|summary|events|geojson ID [-dry-run]",
+			run:   showCommand,
+		},
+		"call": {
+			usage: "calls any function FUNCTION [JSON] [-dry-run]",
+			run:   callCommand,
+		},
+		"export": {
+			usage: "writes all pages of exportState to a file, one per line [-o FILE] [-page-size N] [-dry-run]",
+			run:   exportCommand,
+		},
+		"verify-export": {
+			usage: "checks the pages of exportState for completeness and consistency FILE...",
+			run:   verifyExportCommand,
+		},
+		"gateway": {
+			usage: "runs the REST gateway [-listen ADDR] [-backend memory|peer] ...",
+			run:   gatewayCommand,
+		},
+		"openapi": {
+			usage: "writes an OpenAPI document of the REST gateway [-server URL] [-o FILE]",
+			run:   openapiCommand,
+		},
+	}
+}
+
+func helpCommand(args []string) error {
+	names := []string{}
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	fmt.Fprintf(os.Stderr, "usage: %s COMMAND [ARGS], or without arguments to run the chaincode\n\n", os.Args[0])
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", name, commands[name].usage)
+	}
+	return nil
+}
@@ -0,0 +1,155 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// The configuration is kept under [".", configType, "#", configID].
+// Invocations read it when they first log, see invocationStub.
+const (
+	configType = "Config"
+	configID   = "chaincode"
+)
+
+// loadConfig returns the configuration, which is empty unless it has been
+// set
+func loadConfig(stub shim.ChaincodeStubInterface) (*Config, error) {
+	_, x, err := configRegistry().get(stub, configID)
+	if err == errNotFound {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return x.(*Config), nil
+}
+
+var (
+	getConfigSchema = `
+{
+	"$id": "PreciousCargoShippping:getConfigSchema",
+	"type": "object"
+}
+`
+	getConfigSchemaLoader = gojsonschema.NewStringLoader(getConfigSchema)
+)
+
+// Returns the configuration
+type getConfigArg struct{}
+
+type getConfigResult struct {
+	Config *Config `json:"config"`
+}
+
+type getConfigInvocation struct {
+	arg getConfigArg
+	res getConfigResult
+}
+
+func (inv *getConfigInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getConfigInvocation.checkParseArguments")
+
+	inv.arg = getConfigArg{}
+	return parseArgument(stub, getConfigSchemaLoader, &inv.arg)
+}
+
+func (inv *getConfigInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getConfigInvocation.process")
+
+	c, err := loadConfig(stub)
+	if err != nil {
+		return err
+	}
+	inv.res.Config = c
+	return nil
+}
+
+func (inv *getConfigInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
+
+var (
+	setConfigSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:setConfigSchema",
+	"type": "object",
+	"properties": {
+		"logLevel": {
+			"type": "string",
+			"enum": [ "", "%s", "%s", "%s", "%s" ],
+			"description": "level of log lines to write, empty for the default, unless %s is set"
+		},
+		"logFormat": {
+			"type": "string",
+			"enum": [ "", "%s", "%s" ],
+			"description": "format of log lines, empty for the default, unless %s is set"
+		}
+	},
+	"additionalProperties": false
+}
+`, levelDebug, levelInfo, levelWarn, levelError, logLevelEnv, logFormatText, logFormatJSON, logFormatEnv)
+	setConfigSchemaLoader = gojsonschema.NewStringLoader(setConfigSchema)
+)
+
+// Replaces the configuration. Settings not given are reset to their
+// defaults.
+type setConfigArg struct {
+	LogLevel  string `json:"logLevel"`
+	LogFormat string `json:"logFormat"`
+}
+
+// Returns the new configuration
+type setConfigResult struct {
+	Config *Config `json:"config"`
+}
+
+type setConfigInvocation struct {
+	arg setConfigArg
+	res setConfigResult
+}
+
+func (inv *setConfigInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter setConfigInvocation.checkParseArguments")
+
+	if !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+	inv.arg = setConfigArg{}
+	return parseArgument(stub, setConfigSchemaLoader, &inv.arg)
+}
+
+func (inv *setConfigInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter setConfigInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	identity, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	c := &Config{
+		LogLevel:  inv.arg.LogLevel,
+		LogFormat: inv.arg.LogFormat,
+		UpdatedAt: now,
+		UpdatedBy: identity,
+	}
+	if err = configRegistry().update(stub, configID, c); err != nil {
+		return errors.New("internal error writing world state")
+	}
+	loggerFor(stub).Infof("configuration changed, logLevel=%q logFormat=%q", c.LogLevel, c.LogFormat)
+	inv.res.Config = c
+	return nil
+}
+
+func (inv *setConfigInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
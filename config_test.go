@@ -0,0 +1,51 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestInvocationsLogAtConfiguredLevel(t *testing.T) {
+	var buf bytes.Buffer
+	out, level := logger.out.w, logger.level
+	fixedLevel, fixedFormat := logger.out.fixedLevel, logger.out.fixedFormat
+	logger.SetOutput(&buf)
+	logger.level, logger.out.fixedLevel, logger.out.fixedFormat = levelInfo, false, false
+	defer func() {
+		logger.SetOutput(out)
+		logger.level, logger.out.fixedLevel, logger.out.fixedFormat = level, fixedLevel, fixedFormat
+	}()
+
+	b, err := newMemoryBackend("admin", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		level        string
+		function     string
+		arg          string
+		debug, infos bool
+	}{
+		{level: "error", function: "registerShipmentCo", arg: `{"name":"FastShip","address":{"street":"A","postalCode":"10115","city":"Berlin","country":"DE"}}`},
+		{level: "error", function: "getConfig", arg: `{}`},
+		{level: "info", function: "getConfig", arg: `{}`, infos: true},
+		{level: "debug", function: "registerShipmentCo", arg: `{"name":"SlowShip","address":{"street":"B","postalCode":"10115","city":"Berlin","country":"DE"}}`, debug: true, infos: true},
+		{level: "debug", function: "getConfig", arg: `{}`, debug: true, infos: true},
+	}
+	for _, tt := range tests {
+		if _, err = b.invoke("setConfig", []byte(`{"logLevel":"`+tt.level+`"}`)); err != nil {
+			t.Fatal(err)
+		}
+		buf.Reset()
+		if _, err = call(b, tt.function, []byte(tt.arg)); err != nil {
+			t.Fatal(err)
+		}
+		lines := buf.String()
+		if strings.Contains(lines, " DEBUG ") != tt.debug || strings.Contains(lines, " INFO ") != tt.infos {
+			t.Errorf("%s at %s: unexpected lines\n%s", tt.function, tt.level, lines)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	consolidateShipmentsSchema = `
+{
+	"$id": "PreciousCargoShippping:consolidateShipmentsSchema",
+	"type": "object",
+	"properties": {
+		"ids": {
+			"type": "array",
+			"description": "IDs of Shipments to consolidate",
+			"minItems": 2,
+			"items": { "type": "string" }
+		},
+		"by": {
+			"type": "string",
+			"description": "ID of ShipmentCo consolidating, must have custody of all shipments"
+		},
+		"legs": {
+			"type": "array",
+			"items": { "type": "object" }
+		},
+		"devices": {
+			"type": "array",
+			"items": { "type": "string" }
+		}
+	},
+	"required": [ "ids", "by" ]
+}
+`
+	consolidateShipmentsSchemaLoader = gojsonschema.NewStringLoader(consolidateShipmentsSchema)
+)
+
+// Consolidates shipments into a new shipment, e.g. a container. Legs and
+// devices are those of the new shipment, as in submitShipment.
+type consolidateShipmentsArg struct {
+	IDs     []string               `json:"ids"`
+	Shipper string                 `json:"by"`
+	Legs    []submitShipmentLegArg `json:"legs"`
+	Devices []string               `json:"devices"`
+}
+
+// Returns ID of the new shipment and IDs of the shipments it contains
+type consolidateShipmentsResult struct {
+	ID       string   `json:"id"`
+	ChildIDs []string `json:"children"`
+}
+
+type consolidateShipmentsInvocation struct {
+	eventRecorder
+
+	arg consolidateShipmentsArg
+
+	children []*Shipment
+	legs     []Leg
+
+	res consolidateShipmentsResult
+}
+
+func (inv *consolidateShipmentsInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter consolidateShipmentsInvocation.checkParseArguments")
+
+	inv.arg = consolidateShipmentsArg{}
+	err := parseArgument(stub, consolidateShipmentsSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	_, x, err := shipmentCoRegistry().get(stub, inv.arg.Shipper)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("invalid by argument: Not found")
+	}
+	if err = checkCallerIs(stub, x.(*ShipmentCo).Participant); err != nil {
+		return err
+	}
+
+	inv.legs, err = parseLegs(stub, inv.arg.Shipper, inv.arg.Legs)
+	if err != nil {
+		return fmt.Errorf("invalid legs argument: %s", err)
+	}
+	if err = checkDevices(stub, inv.legs, inv.arg.Devices); err != nil {
+		return fmt.Errorf("invalid devices argument: %s", err)
+	}
+
+	seen := map[string]bool{}
+	for _, id := range inv.arg.IDs {
+		if seen[id] {
+			return fmt.Errorf("invalid ids argument: Duplicate shipment %s", id)
+		}
+		seen[id] = true
+
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return fmt.Errorf("invalid ids argument: Shipment %s not found", id)
+		}
+		child := x.(*Shipment)
+		child.ensureLegs()
+		if err = child.checkActive(); err != nil {
+			return fmt.Errorf("invalid ids argument: %s", err)
+		}
+		if child.Status == ShipmentDelivered {
+			return fmt.Errorf("invalid ids argument: Shipment %s has been delivered already", id)
+		}
+		if child.ParentID != "" {
+			return fmt.Errorf("invalid ids argument: Shipment %s is part of shipment %s", id, child.ParentID)
+		}
+		inv.children = append(inv.children, child)
+	}
+
+	return nil
+}
+
+func (inv *consolidateShipmentsInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter consolidateShipmentsInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+
+	// the consolidating carrier must have all shipments at hand
+	manifests := [][]ManifestItem{}
+	for _, child := range inv.children {
+		if child.custodian(now) != inv.arg.Shipper {
+			return fmt.Errorf("shipment %s is not in custody of %s", child.ID.ID, inv.arg.Shipper)
+		}
+		manifests = append(manifests, child.Manifest)
+	}
+
+	parent := &Shipment{
+		ShipperID:   inv.arg.Shipper,
+		FromID:      commonValue(inv.children, func(s *Shipment) string { return s.FromID }),
+		ToID:        commonValue(inv.children, func(s *Shipment) string { return s.ToID }),
+		Status:      ShipmentSubmitted,
+		Legs:        inv.legs,
+		DeviceIDs:   inv.arg.Devices,
+		Manifest:    mergeManifests(manifests...),
+		ChildIDs:    inv.arg.IDs,
+		SubmittedAt: now,
+	}
+	id, err := saveShipment(stub, &inv.eventRecorder, parent)
+	if err != nil {
+		return err
+	}
+
+	for _, child := range inv.children {
+		child.Status = ShipmentConsolidated
+		child.ParentID = id
+		if _, err = saveShipment(stub, &inv.eventRecorder, child); err != nil {
+			return err
+		}
+	}
+
+	inv.res = consolidateShipmentsResult{
+		ID:       id,
+		ChildIDs: inv.arg.IDs,
+	}
+	inv.emit("ShipmentsConsolidated", inv.res)
+
+	return nil
+}
+
+// commonValue returns the value all shipments agree on, or an empty string
+func commonValue(shipments []*Shipment, value func(*Shipment) string) string {
+	v := value(shipments[0])
+	for _, s := range shipments[1:] {
+		if value(s) != v {
+			return ""
+		}
+	}
+	return v
+}
+
+func (inv *consolidateShipmentsInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,228 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Seal checks are stored under
+//
+//	ns . SealCheck # <container id> <day> <time of day> <seal number> <action>
+//
+// with day and time as for tracking data points, so that a partial key
+// scan returns them in order of time.
+const sealCheckType = "SealCheck"
+
+// intactSeals returns the sorted numbers of all intact seals
+func (c *Container) intactSeals() []string {
+	res := []string{}
+	for _, seal := range c.Seals {
+		if seal.Status == SealIntact {
+			res = append(res, seal.Number)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// seal returns the seal with the given number
+func (c *Container) seal(number string) (*Seal, error) {
+	for i := range c.Seals {
+		if c.Seals[i].Number == number {
+			return &c.Seals[i], nil
+		}
+	}
+	return nil, fmt.Errorf("container %s has no seal %s", c.ID.ID, number)
+}
+
+// getContainer loads a container by ID
+func getContainer(stub shim.ChaincodeStubInterface, id string) (*Container, error) {
+	_, x, err := containerRegistry().get(stub, id)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return nil, errors.New("unable to locate container for this ID")
+	}
+	return x.(*Container), nil
+}
+
+// checkCallerHandlesContainer makes sure the caller is the owner of a
+// container, a carrier of a shipment loaded into it, or an admin.
+func checkCallerHandlesContainer(stub shim.ChaincodeStubInterface, c *Container) error {
+	carriers := []string{c.OwnerID}
+	for _, id := range c.ShipmentIDs {
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return fmt.Errorf("unable to locate loaded shipment %s", id)
+		}
+		s := x.(*Shipment)
+		s.ensureLegs()
+		for _, leg := range s.Legs {
+			carriers = append(carriers, leg.CarrierID)
+		}
+	}
+
+	for _, carrierID := range carriers {
+		_, x, err := shipmentCoRegistry().get(stub, carrierID)
+		if err != nil {
+			continue
+		}
+		if checkCallerIs(stub, x.(*ShipmentCo).Participant) == nil {
+			return nil
+		}
+	}
+	return errNotAuthorized
+}
+
+// putSealCheck records a seal check
+func putSealCheck(stub shim.ChaincodeStubInterface, sc SealCheck) error {
+	attrs := append([]string{".", sealCheckType, "#", sc.ContainerID}, sortableTimeAttrs(sc.At)...)
+	ck, err := stub.CreateCompositeKey(ns, append(attrs, sc.SealNumber, sc.Action))
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error generating composite key")
+	}
+	data, err := json.Marshal(sc)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal JSON marshal error")
+	}
+	err = stub.PutState(ck, data)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error writing world state")
+	}
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, sc)
+
+	return nil
+}
+
+// scanSealChecks calls fn for all seal checks of a container, in order of time
+func scanSealChecks(stub shim.ChaincodeStubInterface, containerID string, fn func(SealCheck) error) error {
+	it, err := stub.GetStateByPartialCompositeKey(ns, []string{".", sealCheckType, "#", containerID})
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error reading from world state")
+	}
+	defer it.Close()
+
+	for it.HasNext() {
+		kv, err := it.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state")
+		}
+		var sc SealCheck
+		err = json.Unmarshal(kv.Value, &sc)
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state (2)")
+		}
+		if err = fn(sc); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// flagShipments adds a flag to all shipments loaded into a container.
+// Returns the flagged shipments.
+func flagShipments(stub shim.ChaincodeStubInterface, ev *eventRecorder, c *Container, flag ShipmentFlag) ([]string, error) {
+	for _, id := range c.ShipmentIDs {
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return nil, fmt.Errorf("unable to locate loaded shipment %s", id)
+		}
+		s := x.(*Shipment)
+		s.Flags = append(s.Flags, flag)
+		if _, err = saveShipment(stub, ev, s); err != nil {
+			return nil, err
+		}
+	}
+	return c.ShipmentIDs, nil
+}
+
+// replaceLoadedShipment replaces a shipment by its parts in the list of
+// shipments loaded into a container, if any.
+func replaceLoadedShipment(stub shim.ChaincodeStubInterface, containerID string, id string, childIDs []string) error {
+	if containerID == "" {
+		return nil
+	}
+	c, err := getContainer(stub, containerID)
+	if err != nil {
+		return err
+	}
+	ids := []string{}
+	for _, loaded := range c.ShipmentIDs {
+		if loaded == id {
+			ids = append(ids, childIDs...)
+		} else {
+			ids = append(ids, loaded)
+		}
+	}
+	c.ShipmentIDs = ids
+	return containerRegistry().update(stub, c.ID.ID, c)
+}
+
+// recordSealsAtHandover takes note of the intact seals of the shipment's
+// container when a leg departs or arrives, and flags the shipment if they
+// differ from those at the previous handover. Returns the flag, if any.
+// The shipment is not saved.
+func recordSealsAtHandover(stub shim.ChaincodeStubInterface, s *Shipment, legIdx int, at time.Time) (*ShipmentFlag, error) {
+	if s.ContainerID == "" {
+		return nil, nil
+	}
+	c, err := getContainer(stub, s.ContainerID)
+	if err != nil {
+		return nil, err
+	}
+	seals := c.intactSeals()
+
+	// previous snapshot, walking back from this handover
+	var previous []string
+	found := false
+	leg := &s.Legs[legIdx]
+	if leg.Status == LegArrived && leg.SealsAtDeparture != nil {
+		previous, found = leg.SealsAtDeparture, true
+	}
+	for i := legIdx - 1; i >= 0 && !found; i-- {
+		if s.Legs[i].SealsAtArrival != nil {
+			previous, found = s.Legs[i].SealsAtArrival, true
+		} else if s.Legs[i].SealsAtDeparture != nil {
+			previous, found = s.Legs[i].SealsAtDeparture, true
+		}
+	}
+
+	if leg.Status == LegArrived {
+		leg.SealsAtArrival = seals
+	} else {
+		leg.SealsAtDeparture = seals
+	}
+
+	if !found || strings.Join(previous, ",") == strings.Join(seals, ",") {
+		return nil, nil
+	}
+	flag := ShipmentFlag{
+		Type: FlagSealChanged,
+		At:   at,
+		Details: fmt.Sprintf("seals of container %s changed from [%s] to [%s] at %s of leg %d",
+			c.ID.ID, strings.Join(previous, ","), strings.Join(seals, ","), leg.Status, legIdx),
+	}
+	s.Flags = append(s.Flags, flag)
+	return &flag, nil
+}
+
+// Emitted when a shipment has been flagged
+type shipmentFlagEvent struct {
+	ShipmentID string       `json:"shipmentId"`
+	Flag       ShipmentFlag `json:"flag"`
+}
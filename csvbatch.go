@@ -0,0 +1,228 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// CSV columns of participants, named like the JSON fields of a
+// participant of bulkRegisterParticipants. A single contact person of
+// a company can be given.
+var csvColumns = []string{
+	"kind", "name", "allowDuplicate",
+	"street", "houseNumber", "postalCode", "city", "region", "country", "line", "lat", "lng",
+	"registrationNumber", "vatId",
+	"contactName", "contactEmail", "contactPhone", "contactRole",
+}
+
+// participant kinds in CSV files, as for register-participant, or the
+// kinds themselves
+var csvKinds = map[string]string{
+	"individual": KindIndividualParticipant,
+	"corporate":  KindCorporateParticipant,
+	"shipmentco": KindShipmentCo,
+}
+
+func csv2batchCommand(args []string) error {
+	flags := flag.NewFlagSet("csv2batch", flag.ContinueOnError)
+	in := flags.String("i", "-", "CSV file of participants with a header line naming the columns, - for stdin")
+	out := flags.String("o", "-", "file to write the batches to, one bulkRegisterParticipants argument per line, - for stdout")
+	size := flags.Int("size", maxParticipantBatchSize, "participants per batch")
+	skipInvalid := flags.Bool("skip-invalid", false, "leave out invalid rows instead of failing")
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: csv2batch [FLAGS]\n\ncolumns: %s\n\n", strings.Join(csvColumns, ", "))
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *size < 1 || *size > maxParticipantBatchSize {
+		return fmt.Errorf("size must be between 1 and %d", maxParticipantBatchSize)
+	}
+
+	r := os.Stdin
+	if *in != "-" {
+		f, err := os.Open(*in)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	rows, invalid, err := readParticipantsCSV(r)
+	if err != nil {
+		return err
+	}
+	if invalid > 0 && !*skipInvalid {
+		return fmt.Errorf("%d invalid rows, fix them or leave them out with -skip-invalid", invalid)
+	}
+	if len(rows) == 0 {
+		return errors.New("no participants")
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	batches := 0
+	for i := 0; i < len(rows); i += *size {
+		end := i + *size
+		if end > len(rows) {
+			end = len(rows)
+		}
+		line, err := json.Marshal(bulkRegisterParticipantsArg{Participants: rows[i:end]})
+		if err != nil {
+			return err
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+		batches++
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d participants in %d batches, %d invalid rows left out\n", len(rows), batches, invalid)
+	return nil
+}
+
+// readParticipantsCSV converts the rows of a CSV file into participants of
+// bulkRegisterParticipants, checked against the schema of their kind.
+// Invalid rows are reported on stderr and counted.
+func readParticipantsCSV(r io.Reader) ([]json.RawMessage, int, error) {
+	cr := csv.NewReader(r)
+	cr.TrimLeadingSpace = true
+	header, err := cr.Read()
+	if err != nil {
+		return nil, 0, fmt.Errorf("unable to read header line: %s", err)
+	}
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.TrimSpace(name)
+		known := false
+		for _, c := range csvColumns {
+			if strings.EqualFold(c, name) {
+				name, known = c, true
+			}
+		}
+		if !known {
+			return nil, 0, fmt.Errorf("unknown column %q, expecting %s", name, strings.Join(csvColumns, ", "))
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"kind", "name"} {
+		if _, found := columns[required]; !found {
+			return nil, 0, fmt.Errorf("missing column %s", required)
+		}
+	}
+
+	rows := []json.RawMessage{}
+	invalid := 0
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+		row, err := participantFromCSV(record, columns)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "row %d: %s\n", n, err)
+			invalid++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, invalid, nil
+}
+
+// participantFromCSV converts a CSV record and checks it like the chaincode
+func participantFromCSV(record []string, columns map[string]int) (json.RawMessage, error) {
+	value := func(name string) string {
+		if i, found := columns[name]; found && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	kind := value("kind")
+	if k, found := csvKinds[strings.ToLower(kind)]; found {
+		kind = k
+	}
+	if _, found := participantRowSchemaLoaders[kind]; !found {
+		return nil, fmt.Errorf("unknown kind %q", kind)
+	}
+
+	address := map[string]interface{}{}
+	for _, name := range []string{"street", "houseNumber", "postalCode", "city", "region", "line"} {
+		if v := value(name); v != "" {
+			address[name] = v
+		}
+	}
+	if v := value("country"); v != "" {
+		address["country"] = strings.ToUpper(v)
+	}
+	if value("lat") != "" || value("lng") != "" {
+		lat, err := strconv.ParseFloat(value("lat"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lat %q", value("lat"))
+		}
+		lng, err := strconv.ParseFloat(value("lng"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid lng %q", value("lng"))
+		}
+		address["geo"] = GeoPoint{Latitude: lat, Longitude: lng}
+	}
+
+	p := map[string]interface{}{
+		"kind":    kind,
+		"name":    value("name"),
+		"address": address,
+	}
+	if v := value("allowDuplicate"); v != "" {
+		allow, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid allowDuplicate %q", v)
+		}
+		p["allowDuplicate"] = allow
+	}
+	if kind == KindCorporateParticipant {
+		p["registrationNumber"] = value("registrationNumber")
+		if v := value("vatId"); v != "" {
+			p["vatId"] = v
+		}
+		if v := value("contactName"); v != "" {
+			p["contacts"] = []ContactPerson{{
+				Name:  v,
+				Email: value("contactEmail"),
+				Phone: value("contactPhone"),
+				Role:  value("contactRole"),
+			}}
+		}
+	}
+
+	row, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	if err = validateJSON(participantRowSchemaLoaders[kind], row); err != nil {
+		return nil, err
+	}
+	return row, nil
+}
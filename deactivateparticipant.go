@@ -0,0 +1,130 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	deactivateParticipantSchema = `
+{
+	"$id": "PreciousCargoShippping:deactivateParticipantSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of a participant of any kind",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"kind": {
+			"type": "string",
+			"description": "optional, to resolve IDs used by more than one kind",
+			"enum": [ "IndividualParticipant", "CorporateParticipant", "ShipmentCo" ]
+		},
+		"reason": {
+			"type": "string",
+			"maxLength": 256
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	deactivateParticipantSchemaLoader = gojsonschema.NewStringLoader(deactivateParticipantSchema)
+)
+
+// Retires a participant of any kind. Deactivated participants cannot be
+// updated, and shipments cannot be submitted by, from or to them.
+type deactivateParticipantArg struct {
+	ID     string `json:"id"`
+	Kind   string `json:"kind"`
+	Reason string `json:"reason"`
+}
+
+// Returns kind and data of the deactivated participant
+type deactivateParticipantResult struct {
+	Kind        string      `json:"kind"`
+	Participant interface{} `json:"participant"`
+}
+
+type deactivateParticipantInvocation struct {
+	eventRecorder
+
+	arg deactivateParticipantArg
+	res deactivateParticipantResult
+
+	kind        string
+	participant interface{}
+}
+
+func (inv *deactivateParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter deactivateParticipantInvocation.checkParseArguments")
+
+	inv.arg = deactivateParticipantArg{}
+	if err := parseArgument(stub, deactivateParticipantSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+
+	kinds := []string{}
+	if inv.arg.Kind != "" {
+		kinds = append(kinds, inv.arg.Kind)
+	}
+	kind, x, err := getAnyParticipant(stub, inv.arg.ID, kinds...)
+	if err == errNotFound {
+		return errors.New("invalid id argument: Not found")
+	}
+	if err != nil {
+		return err
+	}
+	inv.kind, inv.participant = kind, x
+
+	p := participantOf(x)
+	if err = checkCallerIs(stub, *p); err != nil {
+		return err
+	}
+	if !p.active() {
+		return errors.New("invalid id argument: Already deactivated")
+	}
+
+	return nil
+}
+
+// Marks the participant as deactivated. It is not deleted, so it can
+// still be looked up for existing shipments, and its history is kept.
+func (inv *deactivateParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter deactivateParticipantInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	// deactivated participants do not count as duplicates
+	if err = unindexParticipant(stub, inv.kind, inv.participant); err != nil {
+		return err
+	}
+
+	p := participantOf(inv.participant)
+	p.Status = ParticipantDeactivated
+	p.UpdatedAt = &now
+	p.DeactivatedAt = &now
+	p.DeactivationReason = inv.arg.Reason
+
+	if err = participantRegistries(inv.kind)[0].update(stub, p.ID.ID, inv.participant); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: inv.kind, Participant: inv.participant})
+	inv.res = deactivateParticipantResult{
+		Kind:        inv.kind,
+		Participant: inv.participant,
+	}
+
+	return nil
+}
+
+func (inv *deactivateParticipantInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
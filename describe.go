@@ -0,0 +1,73 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// describeFunction lists all functions, it is handled by Invoke itself
+const describeFunction = "describe"
+
+// functionDescription tells clients how to call a function
+type functionDescription struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Role        string `json:"role"`
+	Mutates     bool   `json:"mutates"`
+
+	// JSON schemas of argument and response. Argument schemas are derived
+	// from the argument type if there is no handwritten one, response
+	// schemas always are.
+	Arguments map[string]interface{} `json:"arguments"`
+	Response  map[string]interface{} `json:"response"`
+}
+
+// describeHandlers describes functions, sorted by name
+func describeHandlers(handlers map[string]handlerInfo) ([]functionDescription, error) {
+	res := []functionDescription{}
+	for name, info := range handlers {
+		d := functionDescription{
+			Name:        name,
+			Description: info.description,
+			Role:        info.role,
+			Mutates:     info.mutates,
+		}
+		if info.schema != "" {
+			if err := json.Unmarshal([]byte(info.schema), &d.Arguments); err != nil {
+				return nil, fmt.Errorf("invalid schema of %s: %s", name, err)
+			}
+		} else if f, ok := info.typ.FieldByName("arg"); ok {
+			d.Arguments = typeSchema(f.Type)
+		}
+		if f, ok := info.typ.FieldByName("res"); ok {
+			d.Response = typeSchema(f.Type)
+		}
+		res = append(res, d)
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Name < res[j].Name })
+	return res, nil
+}
+
+// describe responds with descriptions of all functions
+func (cci *PreciousCargoChaincode) describe(stub shim.ChaincodeStubInterface) pb.Response {
+	descriptions, err := describeHandlers(cci.handlers)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return shim.Error("internal error describing functions")
+	}
+	data, err := json.Marshal(map[string]interface{}{
+		"namespace": ns,
+		"functions": descriptions,
+	})
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return shim.Error("Internal JSON marshal error (response).")
+	}
+	return success(data, false)
+}
@@ -0,0 +1,144 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Participants are indexed by fingerprints of their normalised name and
+// address, and of their registration number for companies. Keys are
+// [".", participantFingerprintIndex, "#", kind, fingerprint, id], so that
+// participants sharing a fingerprint are found by a partial key.
+// Fingerprints are hashed to keep personal data out of keys.
+//
+// Participants registered before the index existed are indexed when they
+// are updated next.
+const participantFingerprintIndex = "ParticipantFingerprint"
+
+// normalise lowercases s and reduces it to words of letters and digits,
+// so that spelling variants in case, spacing and punctuation match.
+func normalise(s string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
+
+func fingerprint(parts ...string) string {
+	h := sha256.New()
+	for _, part := range parts {
+		h.Write([]byte(normalise(part)))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// participantFingerprints returns the fingerprints of a participant
+// of any kind.
+func participantFingerprints(x interface{}) []string {
+	name, address := describeParticipant(x)
+	res := []string{"na:" + fingerprint(name, address.String())}
+	if p, ok := x.(*CorporateParticipant); ok && p.RegistrationNumber != "" {
+		res = append(res, "rn:"+fingerprint(p.Address.Country, p.RegistrationNumber))
+	}
+	return res
+}
+
+// findDuplicateParticipants returns the IDs of active participants of the
+// same kind sharing a fingerprint with the given one, except itself.
+func findDuplicateParticipants(stub shim.ChaincodeStubInterface, kind string, x interface{}) ([]string, error) {
+	self := participantOf(x).ID.ID
+	seen := map[string]bool{}
+	res := []string{}
+	for _, fp := range participantFingerprints(x) {
+		iter, err := stub.GetStateByPartialCompositeKey(ns, []string{".", participantFingerprintIndex, "#", kind, fp})
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return nil, errors.New("internal error reading from world state")
+		}
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				iter.Close()
+				loggerFor(stub).Error(err)
+				return nil, errors.New("internal error reading from world state")
+			}
+			id := string(kv.Value)
+			if id == self || seen[id] {
+				continue
+			}
+			seen[id] = true
+			res = append(res, id)
+		}
+		iter.Close()
+	}
+	return res, nil
+}
+
+// checkDuplicateParticipants rejects a participant which duplicates
+// others, unless allowed. Returns the IDs of duplicates.
+func checkDuplicateParticipants(stub shim.ChaincodeStubInterface, kind string, x interface{}, allow bool) ([]string, error) {
+	duplicates, err := findDuplicateParticipants(stub, kind, x)
+	if err != nil {
+		return nil, err
+	}
+	if len(duplicates) > 0 {
+		if !allow {
+			return nil, fmt.Errorf("duplicate of participant(s) %s, set allowDuplicate to register anyway", strings.Join(duplicates, ", "))
+		}
+		loggerFor(stub).Infof("registering duplicate of participant(s) %s", strings.Join(duplicates, ", "))
+	}
+	return duplicates, nil
+}
+
+func participantFingerprintKeys(stub shim.ChaincodeStubInterface, kind string, x interface{}) ([]string, error) {
+	id := participantOf(x).ID.ID
+	res := []string{}
+	for _, fp := range participantFingerprints(x) {
+		ck, err := stub.CreateCompositeKey(ns, []string{".", participantFingerprintIndex, "#", kind, fp, id})
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return nil, errors.New("internal error generating composite key")
+		}
+		res = append(res, ck)
+	}
+	return res, nil
+}
+
+// indexParticipant adds a participant to the fingerprint index
+func indexParticipant(stub shim.ChaincodeStubInterface, kind string, x interface{}) error {
+	keys, err := participantFingerprintKeys(stub, kind, x)
+	if err != nil {
+		return err
+	}
+	for _, ck := range keys {
+		if err = stub.PutState(ck, []byte(participantOf(x).ID.ID)); err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error writing world state")
+		}
+	}
+	return nil
+}
+
+// unindexParticipant removes a participant from the fingerprint index,
+// e.g. before its name or address changes.
+func unindexParticipant(stub shim.ChaincodeStubInterface, kind string, x interface{}) error {
+	keys, err := participantFingerprintKeys(stub, kind, x)
+	if err != nil {
+		return err
+	}
+	for _, ck := range keys {
+		if err = stub.DelState(ck); err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error writing world state")
+		}
+	}
+	return nil
+}
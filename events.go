@@ -0,0 +1,55 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// chaincodeEvent is a single business event. Fabric keeps only one
+// chaincode event per transaction, so all events of an invocation are
+// sent together as a JSON array in one chaincode event named ns.
+type chaincodeEvent struct {
+	Type    string      `json:"type"`
+	Payload interface{} `json:"payload"`
+}
+
+// eventEmitter is implemented by InvocationHandlers which emit events.
+type eventEmitter interface {
+	getEvents() []chaincodeEvent
+}
+
+// eventRecorder can be embedded into an invocation to collect events
+// while processing. Invoke sends them after successful processing.
+type eventRecorder struct {
+	events []chaincodeEvent
+}
+
+func (r *eventRecorder) emit(typ string, payload interface{}) {
+	r.events = append(r.events, chaincodeEvent{Type: typ, Payload: payload})
+}
+
+func (r *eventRecorder) getEvents() []chaincodeEvent {
+	return r.events
+}
+
+// sendEvents sets the chaincode event for all events of an invocation
+func sendEvents(stub shim.ChaincodeStubInterface, events []chaincodeEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	data, err := json.Marshal(events)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal JSON marshal error (events)")
+	}
+	err = stub.SetEvent(ns, data)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error setting event")
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// exportCommand pages through exportState and writes the pages to a file,
+// one per line, for verify-export.
+func exportCommand(args []string) error {
+	c := newClient("export")
+	out := c.flags.String("o", "-", "file to write the pages to, one per line, - for stdout")
+	pageSize := c.flags.Int("page-size", defaultExportStatePageSize, "entries per page")
+	if err := c.parse(args); err != nil {
+		return err
+	}
+	if *pageSize < 1 || *pageSize > maxExportStatePageSize {
+		return fmt.Errorf("page-size must be between 1 and %d", maxExportStatePageSize)
+	}
+	b, err := c.newBackend()
+	if err != nil {
+		return err
+	}
+
+	w := os.Stdout
+	if *out != "-" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	bw := bufio.NewWriter(w)
+	arg := exportStateArg{PageSize: int32(*pageSize)}
+	pages, entries := 0, 0
+	for {
+		data, err := json.Marshal(arg)
+		if err != nil {
+			return err
+		}
+		payload, err := call(b, "exportState", data)
+		if err != nil {
+			return fmt.Errorf("page %d: %s", pages+1, err)
+		}
+		var page exportStateResult
+		if err = json.Unmarshal(payload, &page); err != nil {
+			return fmt.Errorf("page %d: %s", pages+1, err)
+		}
+		// written as returned, one per line
+		var line bytes.Buffer
+		if err = json.Compact(&line, payload); err != nil {
+			return fmt.Errorf("page %d: %s", pages+1, err)
+		}
+		line.WriteByte('\n')
+		line.WriteTo(bw)
+		pages++
+		entries += len(page.Entries)
+		if page.Bookmark == "" {
+			break
+		}
+		arg.Bookmark, arg.PreviousHash = page.Bookmark, page.Hash
+	}
+	if err = bw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "%d entries in %d pages\n", entries, pages)
+	return nil
+}
+
+// verifyExportCommand checks pages of exportState offline: that they chain
+// up from the first to the last page, that their hashes match, and that
+// entities decode and refer to each other as they should.
+func verifyExportCommand(args []string) error {
+	flags := flag.NewFlagSet("verify-export", flag.ContinueOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: verify-export FILE...\n\n"+
+			"Files hold a page of exportState each, or one page per line, in any order.\n")
+	}
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return errors.New("no files")
+	}
+
+	pages := []*exportStateResult{}
+	for _, file := range flags.Args() {
+		p, err := readExportPages(file)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		pages = append(pages, p...)
+	}
+
+	v := newExportVerifier()
+	chain := v.chainPages(pages)
+	for i, page := range chain {
+		v.checkPage(i+1, page)
+	}
+	v.checkReferences()
+	v.report(os.Stdout, chain)
+
+	if len(v.problems) > 0 {
+		for _, p := range v.problems {
+			fmt.Fprintln(os.Stderr, p)
+		}
+		return fmt.Errorf("export is incomplete or inconsistent, %d problems", len(v.problems))
+	}
+	fmt.Println("export is complete and consistent")
+	return nil
+}
+
+// readExportPages reads a file holding pages, e.g. a single one or one per
+// line
+func readExportPages(file string) ([]*exportStateResult, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	pages := []*exportStateResult{}
+	dec := json.NewDecoder(bufio.NewReader(f))
+	for {
+		page := &exportStateResult{}
+		err := dec.Decode(page)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %s", len(pages)+1, err)
+		}
+		pages = append(pages, page)
+	}
+	if len(pages) == 0 {
+		return nil, errors.New("no pages")
+	}
+	return pages, nil
+}
+
+// exportVerifier collects what the pages hold and the problems found
+type exportVerifier struct {
+	problems []string
+
+	namespace   string
+	first, last time.Time
+	lastKey     string
+	entries     int
+	counts      map[string]int
+
+	// IDs of entities by the type in their keys
+	ids map[string]map[string]bool
+	// ID counters by index name
+	counters map[string]uint64
+
+	// keys of entries referring to entities
+	shipmentIndex  [][]string
+	fingerprints   [][]string
+	trackingPoints [][]string
+	routeEvents    [][]string
+	sealChecks     [][]string
+}
+
+func newExportVerifier() *exportVerifier {
+	return &exportVerifier{
+		counts:   map[string]int{},
+		ids:      map[string]map[string]bool{},
+		counters: map[string]uint64{},
+	}
+}
+
+func (v *exportVerifier) problem(format string, a ...interface{}) {
+	v.problems = append(v.problems, fmt.Sprintf(format, a...))
+}
+
+// chainPages orders the pages by their hashes, starting with the one
+// without a previous page. Copies of a page are dropped, pages not linked
+// to the chain are reported.
+func (v *exportVerifier) chainPages(pages []*exportStateResult) []*exportStateResult {
+	next := map[string]*exportStateResult{}
+	for _, page := range pages {
+		other, found := next[page.PreviousHash]
+		if !found {
+			next[page.PreviousHash] = page
+			continue
+		}
+		if other.Hash != page.Hash {
+			v.problem("pages %s and %s both follow %q, they are from different exports", other.Hash, page.Hash, page.PreviousHash)
+		}
+	}
+
+	chain := []*exportStateResult{}
+	seen := map[string]bool{}
+	for page, found := next[""]; found; page, found = next[page.Hash] {
+		if seen[page.Hash] {
+			v.problem("page %s is linked in a cycle", page.Hash)
+			break
+		}
+		seen[page.Hash] = true
+		chain = append(chain, page)
+		if page.Bookmark == "" {
+			break
+		}
+	}
+	switch {
+	case len(chain) == 0:
+		v.problem("first page missing")
+	case chain[len(chain)-1].Bookmark != "":
+		v.problem("pages missing after page %d, the last one found has a bookmark", len(chain))
+	}
+	unlinked := 0
+	for _, page := range next {
+		if !seen[page.Hash] {
+			unlinked++
+		}
+	}
+	if unlinked > 0 {
+		v.problem("%d pages not linked to the chain", unlinked)
+	}
+	return chain
+}
+
+// checkPage checks the hash of a page and the order of its entries, and
+// collects the entries
+func (v *exportVerifier) checkPage(n int, page *exportStateResult) {
+	hash, err := exportPageHash(page.PreviousHash, page.Entries, page.Bookmark)
+	if err != nil {
+		v.problem("page %d: %s", n, err)
+	} else if hash != page.Hash {
+		v.problem("page %d: hash is %s, page says %s", n, hash, page.Hash)
+	}
+	if n == 1 {
+		v.namespace = page.Namespace
+		if v.namespace != ns {
+			v.problem("namespace is %s, not %s", v.namespace, ns)
+		}
+	} else if page.Namespace != v.namespace {
+		v.problem("page %d: namespace is %s, not %s", n, page.Namespace, v.namespace)
+	}
+	if v.first.IsZero() || page.ExportedAt.Before(v.first) {
+		v.first = page.ExportedAt
+	}
+	if page.ExportedAt.After(v.last) {
+		v.last = page.ExportedAt
+	}
+
+	counts := map[string]int{}
+	for i, e := range page.Entries {
+		key := e.key()
+		if key <= v.lastKey {
+			v.problem("page %d: entry %d %v is out of order", n, i, e.Key)
+		}
+		v.lastKey = key
+
+		category := exportCategory(e.Key)
+		if category != e.Category {
+			v.problem("page %d: entry %d %v is a %s, not a %s", n, i, e.Key, category, e.Category)
+		}
+		counts[category]++
+		v.counts[category]++
+		v.entries++
+		v.collect(n, i, category, e)
+	}
+	if len(counts) > 0 || len(page.Counts) > 0 {
+		if !reflect.DeepEqual(counts, page.Counts) {
+			v.problem("page %d: counts are %v, page says %v", n, counts, page.Counts)
+		}
+	}
+}
+
+// collect decodes an entry into its type and remembers what it refers to
+func (v *exportVerifier) collect(n, i int, category string, e exportEntry) {
+	switch category {
+	case exportCounter:
+		value, _ := e.value()
+		counter, err := strconv.ParseUint(string(value), 10, 64)
+		if err != nil {
+			v.problem("page %d: entry %d %v is not a number", n, i, e.Key)
+			return
+		}
+		v.counters[e.Key[1]] = counter
+		return
+	case exportIndex:
+		if e.Key[1] == shipmentIndex {
+			v.shipmentIndex = append(v.shipmentIndex, e.Key)
+		} else {
+			v.fingerprints = append(v.fingerprints, e.Key)
+		}
+		return
+	case exportOther:
+		return
+	}
+
+	typ := e.Key[1]
+	if len(e.Value) == 0 {
+		v.problem("page %d: entry %d %v is not JSON", n, i, e.Key)
+		return
+	}
+	if err := json.Unmarshal(e.Value, reflect.New(exportTypes[typ]).Interface()); err != nil {
+		v.problem("page %d: entry %d %v is not a %s: %s", n, i, e.Key, typ, err)
+		return
+	}
+	switch category {
+	case exportTrackingPoint:
+		v.trackingPoints = append(v.trackingPoints, e.Key)
+	case exportRouteEvent:
+		v.routeEvents = append(v.routeEvents, e.Key)
+	case exportSealCheck:
+		v.sealChecks = append(v.sealChecks, e.Key)
+	case exportConfig:
+	default:
+		// entities with an ID, which is the last attribute of their key
+		var id ID
+		json.Unmarshal(e.Value, &id)
+		if len(e.Key) != 4 || id.ID != e.Key[3] {
+			v.problem("page %d: entry %d %v has ID %q", n, i, e.Key, id.ID)
+			return
+		}
+		if v.ids[typ] == nil {
+			v.ids[typ] = map[string]bool{}
+		}
+		v.ids[typ][id.ID] = true
+	}
+}
+
+// checkReferences checks that index entries, tracking points and events
+// refer to exported entities, and that no ID is beyond its counter
+func (v *exportVerifier) checkReferences() {
+	missing := func(what string, key []string, typ, id string) {
+		if !v.ids[typ][id] {
+			v.problem("%s %v refers to missing %s %s", what, key, typ, id)
+		}
+	}
+	for _, key := range v.shipmentIndex {
+		missing("index entry", key, shipmentDocType, key[len(key)-1])
+	}
+	for _, key := range v.fingerprints {
+		// [".", participantFingerprintIndex, "#", kind, fingerprint, id]
+		if len(key) == 6 {
+			missing("fingerprint", key, key[3], key[5])
+		}
+	}
+	for _, key := range v.trackingPoints {
+		missing("tracking point", key, shipmentDocType, key[3])
+	}
+	for _, key := range v.routeEvents {
+		missing("route event", key, shipmentDocType, key[3])
+	}
+	for _, key := range v.sealChecks {
+		missing("seal check", key, "Container", key[3])
+	}
+
+	for typ, ids := range v.ids {
+		counter := v.counters[typ]
+		if exportCategories[typ] == exportParticipant && v.counters[participantIndex] > counter {
+			counter = v.counters[participantIndex]
+		}
+		for id := range ids {
+			n, err := strconv.ParseUint(id, 10, 64)
+			if err != nil {
+				continue
+			}
+			if n > counter {
+				v.problem("%s %s is beyond its ID counter %d", typ, id, counter)
+			}
+		}
+	}
+}
+
+func (v *exportVerifier) report(w io.Writer, chain []*exportStateResult) {
+	fmt.Fprintf(w, "namespace  %s\n", v.namespace)
+	fmt.Fprintf(w, "pages      %d\n", len(chain))
+	fmt.Fprintf(w, "entries    %d\n", v.entries)
+	categories := []string{}
+	for c := range v.counts {
+		categories = append(categories, c)
+	}
+	sort.Strings(categories)
+	for _, c := range categories {
+		fmt.Fprintf(w, "  %-14s %d\n", c, v.counts[c])
+	}
+	if len(chain) > 0 {
+		fmt.Fprintf(w, "exported   %s to %s\n", v.first.Format(time.RFC3339), v.last.Format(time.RFC3339))
+		fmt.Fprintf(w, "last hash  %s\n", chain[len(chain)-1].Hash)
+	}
+}
@@ -0,0 +1,330 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	defaultExportStatePageSize = 200
+	maxExportStatePageSize     = 1000
+)
+
+var (
+	exportStateSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:exportStateSchema",
+	"type": "object",
+	"properties": {
+		"pageSize": {
+			"type": "integer",
+			"minimum": 1,
+			"maximum": %d
+		},
+		"bookmark": {
+			"type": "string",
+			"description": "bookmark of the previous page"
+		},
+		"previousHash": {
+			"type": "string",
+			"pattern": "^([0-9a-f]{64})?$",
+			"description": "hash of the previous page, to chain the pages"
+		}
+	}
+}
+`, maxExportStatePageSize)
+	exportStateSchemaLoader = gojsonschema.NewStringLoader(exportStateSchema)
+)
+
+// Categories of exported entries, by the type in their keys. Keys of the
+// form [".", type, ".", "index"] are ID counters, unknown types are
+// exported as "other".
+const (
+	exportParticipant   = "participant"
+	exportShipment      = "shipment"
+	exportTrackingPoint = "trackingPoint"
+	exportRouteEvent    = "routeEvent"
+	exportDevice        = "device"
+	exportContainer     = "container"
+	exportSealCheck     = "sealCheck"
+	exportConfig        = "config"
+	exportIndex         = "index"
+	exportCounter       = "counter"
+	exportOther         = "other"
+)
+
+var exportCategories = map[string]string{
+	KindIndividualParticipant:   exportParticipant,
+	KindCorporateParticipant:    exportParticipant,
+	KindShipmentCo:              exportParticipant,
+	shipmentDocType:             exportShipment,
+	trackingDataPointType:       exportTrackingPoint,
+	geofenceEventType:           exportRouteEvent,
+	"Device":                    exportDevice,
+	"Container":                 exportContainer,
+	sealCheckType:               exportSealCheck,
+	configType:                  exportConfig,
+	shipmentIndex:               exportIndex,
+	participantFingerprintIndex: exportIndex,
+}
+
+// Go types of exported entities, by the type in their keys
+var exportTypes = map[string]reflect.Type{
+	KindIndividualParticipant: reflect.TypeOf(IndividualParticipant{}),
+	KindCorporateParticipant:  reflect.TypeOf(CorporateParticipant{}),
+	KindShipmentCo:            reflect.TypeOf(ShipmentCo{}),
+	shipmentDocType:           reflect.TypeOf(Shipment{}),
+	trackingDataPointType:     reflect.TypeOf(TrackingDataPoint{}),
+	geofenceEventType:         reflect.TypeOf(GeofenceEvent{}),
+	"Device":                  reflect.TypeOf(Device{}),
+	"Container":               reflect.TypeOf(Container{}),
+	sealCheckType:             reflect.TypeOf(SealCheck{}),
+	configType:                reflect.TypeOf(Config{}),
+}
+
+// exportCategory categorizes a key by its attributes
+func exportCategory(attrs []string) string {
+	if len(attrs) == 4 && attrs[0] == "." && attrs[2] == "." && attrs[3] == "index" {
+		return exportCounter
+	}
+	if len(attrs) >= 3 && attrs[0] == "." && attrs[2] == "#" {
+		if category, found := exportCategories[attrs[1]]; found {
+			return category
+		}
+	}
+	return exportOther
+}
+
+// Exports the world state of the chaincode page by page, in order of keys.
+// Each page continues with the bookmark and the hash of the previous one.
+type exportStateArg struct {
+	PageSize     int32  `json:"pageSize"`
+	Bookmark     string `json:"bookmark"`
+	PreviousHash string `json:"previousHash"`
+}
+
+// A key and its value. JSON values are exported as they are, others as
+// text, or base64 encoded if they are not UTF-8.
+type exportEntry struct {
+	Key      []string        `json:"key"` // attributes of the composite key
+	Category string          `json:"category"`
+	Value    json.RawMessage `json:"value,omitempty"`
+	Text     string          `json:"text,omitempty"`
+	Raw      []byte          `json:"raw,omitempty"`
+}
+
+// Returns a page of entries. hash covers the hash of the previous page, the
+// entries and the bookmark, which continues with the next page and is empty
+// on the last one. Pages are read in separate transactions, exportedAt
+// tells when.
+type exportStateResult struct {
+	Namespace    string         `json:"namespace"`
+	ExportedAt   time.Time      `json:"exportedAt"`
+	PreviousHash string         `json:"previousHash"`
+	Hash         string         `json:"hash"`
+	Counts       map[string]int `json:"counts"`
+	Entries      []exportEntry  `json:"entries"`
+	Bookmark     string         `json:"bookmark,omitempty"`
+}
+
+type exportStateInvocation struct {
+	arg exportStateArg
+	res exportStateResult
+}
+
+func (inv *exportStateInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter exportStateInvocation.checkParseArguments")
+
+	if !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+	inv.arg = exportStateArg{}
+	if err := parseArgument(stub, exportStateSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+	if inv.arg.PageSize == 0 {
+		inv.arg.PageSize = defaultExportStatePageSize
+	}
+	return nil
+}
+
+func (inv *exportStateInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter exportStateInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	inv.res = exportStateResult{
+		Namespace:    ns,
+		ExportedAt:   now,
+		PreviousHash: inv.arg.PreviousHash,
+		Counts:       map[string]int{},
+		Entries:      []exportEntry{},
+	}
+	keys, values, bookmark, err := exportPage(stub, inv.arg.PageSize, inv.arg.Bookmark)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		_, attrs, err := stub.SplitCompositeKey(key)
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error splitting composite key")
+		}
+		entry := newExportEntry(attrs, values[i])
+		inv.res.Entries = append(inv.res.Entries, entry)
+		inv.res.Counts[entry.Category]++
+	}
+	inv.res.Bookmark = bookmark
+
+	inv.res.Hash, err = exportPageHash(inv.res.PreviousHash, inv.res.Entries, inv.res.Bookmark)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error hashing page")
+	}
+	return nil
+}
+
+// exportPage reads a page of keys in the namespace. Returns the bookmark of
+// the next page, empty if this is the last one.
+func exportPage(stub shim.ChaincodeStubInterface, pageSize int32, bookmark string) ([]string, [][]byte, string, error) {
+	keys := []string{}
+	values := [][]byte{}
+
+	iter, meta, err := stub.GetStateByPartialCompositeKeyWithPagination(ns, []string{}, pageSize, bookmark)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return nil, nil, "", errors.New("internal error reading from world state")
+	}
+	if iter != nil {
+		defer iter.Close()
+		for iter.HasNext() {
+			kv, err := iter.Next()
+			if err != nil {
+				loggerFor(stub).Error(err)
+				return nil, nil, "", errors.New("internal error reading from world state")
+			}
+			keys = append(keys, kv.Key)
+			values = append(values, kv.Value)
+		}
+		next := ""
+		if meta != nil && int32(len(keys)) == pageSize {
+			next = meta.Bookmark
+		}
+		return keys, values, next, nil
+	}
+
+	// test stubs do not paginate, the bookmark is the first key of the
+	// next page then
+	iter, err = stub.GetStateByPartialCompositeKey(ns, []string{})
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return nil, nil, "", errors.New("internal error reading from world state")
+	}
+	defer iter.Close()
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return nil, nil, "", errors.New("internal error reading from world state")
+		}
+		if kv.Key < bookmark {
+			continue
+		}
+		if int32(len(keys)) == pageSize {
+			return keys, values, kv.Key, nil
+		}
+		keys = append(keys, kv.Key)
+		values = append(values, kv.Value)
+	}
+	return keys, values, "", nil
+}
+
+func newExportEntry(attrs []string, value []byte) exportEntry {
+	entry := exportEntry{
+		Key:      attrs,
+		Category: exportCategory(attrs),
+	}
+	// only values which are exported byte by byte can be hashed again
+	if json.Valid(value) {
+		if data, err := json.Marshal(json.RawMessage(value)); err == nil && bytes.Equal(data, value) {
+			entry.Value = value
+			return entry
+		}
+	}
+	if utf8.Valid(value) {
+		entry.Text = string(value)
+	} else {
+		entry.Raw = value
+	}
+	return entry
+}
+
+// key returns the composite key of an entry, as created by
+// shim.CreateCompositeKey
+func (e exportEntry) key() string {
+	var b bytes.Buffer
+	b.WriteString("\x00" + ns + "\x00")
+	for _, attr := range e.Key {
+		b.WriteString(attr + "\x00")
+	}
+	return b.String()
+}
+
+// value returns the value of an entry as stored. JSON values are
+// compacted, in case the page has been reformatted.
+func (e exportEntry) value() ([]byte, error) {
+	switch {
+	case len(e.Value) > 0:
+		var b bytes.Buffer
+		if err := json.Compact(&b, e.Value); err != nil {
+			return nil, err
+		}
+		return b.Bytes(), nil
+	case e.Text != "":
+		return []byte(e.Text), nil
+	}
+	return e.Raw, nil
+}
+
+// exportPageHash hashes the hash of the previous page, the length prefixed
+// keys and values of the entries, and the bookmark. Returns it hex encoded.
+func exportPageHash(previousHash string, entries []exportEntry, bookmark string) (string, error) {
+	h := sha256.New()
+	write := func(data []byte) {
+		var n [8]byte
+		binary.BigEndian.PutUint64(n[:], uint64(len(data)))
+		h.Write(n[:])
+		h.Write(data)
+	}
+	write([]byte(previousHash))
+	for _, e := range entries {
+		value, err := e.value()
+		if err != nil {
+			return "", err
+		}
+		write([]byte(e.key()))
+		write(value)
+	}
+	write([]byte(bookmark))
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func (inv *exportStateInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
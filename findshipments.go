@@ -0,0 +1,148 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	defaultFindShipmentsPageSize = 50
+	maxFindShipmentsPageSize     = 200
+)
+
+var (
+	findShipmentsSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:findShipmentsSchema",
+	"type": "object",
+	"properties": {
+		"shipper": { "type": "string", "pattern": "^([0-9]{4,32})$" },
+		"from": { "type": "string", "pattern": "^([0-9]{4,32})$" },
+		"to": { "type": "string", "pattern": "^([0-9]{4,32})$" },
+		"carrier": { "type": "string", "pattern": "^([0-9]{4,32})$" },
+		"status": {
+			"type": "string",
+			"enum": [ "submitted", "in-transit", "delivered", "split", "consolidated" ]
+		},
+		"pageSize": {
+			"type": "integer",
+			"minimum": 1,
+			"maximum": %d
+		},
+		"bookmark": {
+			"type": "string",
+			"description": "bookmark of the previous page"
+		}
+	},
+	"anyOf": [
+		{ "required": [ "shipper" ] },
+		{ "required": [ "from" ] },
+		{ "required": [ "to" ] },
+		{ "required": [ "carrier" ] },
+		{ "required": [ "status" ] }
+	]
+}
+`, maxFindShipmentsPageSize)
+	findShipmentsSchemaLoader = gojsonschema.NewStringLoader(findShipmentsSchema)
+)
+
+// Finds shipments by participants and/or status. All given filters
+// must match.
+type findShipmentsArg struct {
+	Shipper  string `json:"shipper"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Carrier  string `json:"carrier"`
+	Status   string `json:"status"`
+	PageSize int    `json:"pageSize"`
+	Bookmark string `json:"bookmark"`
+}
+
+// Returns a page of shipments in order of their IDs. If the page is full,
+// bookmark continues with the next page.
+type findShipmentsResult struct {
+	Shipments []*Shipment `json:"shipments"`
+	Bookmark  string      `json:"bookmark,omitempty"`
+}
+
+type findShipmentsInvocation struct {
+	arg findShipmentsArg
+	res findShipmentsResult
+}
+
+func (inv *findShipmentsInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter findShipmentsInvocation.checkParseArguments")
+
+	inv.arg = findShipmentsArg{}
+	if err := parseArgument(stub, findShipmentsSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+	if inv.arg.PageSize == 0 {
+		inv.arg.PageSize = defaultFindShipmentsPageSize
+	}
+	return nil
+}
+
+// filters returns the given filters, and the field to scan the index by.
+// Participants are more selective than the status, so they go first.
+func (arg findShipmentsArg) filters() (map[string]string, string) {
+	filters := map[string]string{}
+	scanBy := ""
+	for _, f := range []struct{ field, value string }{
+		{shipmentByFrom, arg.From},
+		{shipmentByTo, arg.To},
+		{shipmentByShipper, arg.Shipper},
+		{shipmentByCarrier, arg.Carrier},
+		{shipmentByStatus, arg.Status},
+	} {
+		if f.value == "" {
+			continue
+		}
+		filters[f.field] = f.value
+		if scanBy == "" {
+			scanBy = f.field
+		}
+	}
+	return filters, scanBy
+}
+
+// Scans the index of one filter and checks the others on the shipments.
+// Checking all filters also skips index entries a shipment has been saved
+// over within the transaction that wrote them.
+func (inv *findShipmentsInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter findShipmentsInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	filters, scanBy := inv.arg.filters()
+
+	inv.res = findShipmentsResult{
+		Shipments: []*Shipment{},
+	}
+	return scanShipmentIndex(stub, scanBy, filters[scanBy], inv.arg.Bookmark, func(id string) (bool, error) {
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err == errNotFound {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		s := x.(*Shipment)
+		if !s.matches(filters) {
+			return true, nil
+		}
+		inv.res.Shipments = append(inv.res.Shipments, s)
+		if len(inv.res.Shipments) == inv.arg.PageSize {
+			inv.res.Bookmark = id
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+func (inv *findShipmentsInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
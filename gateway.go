@@ -0,0 +1,247 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+)
+
+// maximum size of request bodies
+const maxGatewayBodySize = 1 << 20
+
+// gatewayRoute maps a REST route to a chaincode function. Path parameters
+// and query parameters are passed as arguments, together with the fields
+// of the JSON request body.
+type gatewayRoute struct {
+	method   string
+	pattern  string // e.g. /shipments/{id}
+	function string
+	query    []string // query parameters
+}
+
+var gatewayRoutes = []gatewayRoute{
+	{method: http.MethodPost, pattern: "/shipments", function: "submitShipment"},
+	{method: http.MethodGet, pattern: "/shipments/{id}", function: "getShipment"},
+	{method: http.MethodPost, pattern: "/shipments/{id}/tracking", function: "trackShipment"},
+	{method: http.MethodGet, pattern: "/participants/{id}", function: "getParticipant", query: []string{"kind"}},
+}
+
+// match returns the path parameters if path matches the route's pattern
+func (r gatewayRoute) match(path string) (map[string]string, bool) {
+	patternParts := strings.Split(strings.Trim(r.pattern, "/"), "/")
+	pathParts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(patternParts) != len(pathParts) {
+		return nil, false
+	}
+	params := map[string]string{}
+	for i, part := range patternParts {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			if pathParts[i] == "" {
+				return nil, false
+			}
+			params[strings.Trim(part, "{}")] = pathParts[i]
+			continue
+		}
+		if part != pathParts[i] {
+			return nil, false
+		}
+	}
+	return params, true
+}
+
+// pathParams returns the names of the route's path parameters
+func (r gatewayRoute) pathParams() []string {
+	res := []string{}
+	for _, part := range strings.Split(r.pattern, "/") {
+		if strings.HasPrefix(part, "{") && strings.HasSuffix(part, "}") {
+			res = append(res, strings.Trim(part, "{}"))
+		}
+	}
+	return res
+}
+
+// gateway serves chaincode functions over HTTP: the REST routes, all
+// functions at functionsPath + name, their descriptions at functionsPath
+// and an OpenAPI document at /openapi.json.
+type gateway struct {
+	backend    backend
+	serverURL  string
+	corsOrigin string
+}
+
+func (g *gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger.Infof("gateway: %s %s", r.Method, r.URL.Path)
+
+	if g.corsOrigin != "" {
+		w.Header().Set("Access-Control-Allow-Origin", g.corsOrigin)
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+
+	path := r.URL.Path
+	switch {
+	case path == "/openapi.json" && r.Method == http.MethodGet:
+		doc, err := openAPIDocument(handlers, g.serverURL)
+		if err != nil {
+			writeGatewayError(w, err)
+			return
+		}
+		writeGatewayJSON(w, doc)
+		return
+
+	case path == strings.TrimSuffix(functionsPath, "/") && r.Method == http.MethodGet:
+		g.serveFunction(w, r, describeFunction, nil)
+		return
+
+	case strings.HasPrefix(path, functionsPath):
+		function := strings.TrimPrefix(path, functionsPath)
+		if _, found := handlers[function]; !found {
+			writeGatewayStatus(w, http.StatusNotFound, "unknown function "+function)
+			return
+		}
+		if r.Method != http.MethodPost {
+			writeGatewayStatus(w, http.StatusMethodNotAllowed, "use POST")
+			return
+		}
+		g.serveFunction(w, r, function, nil)
+		return
+	}
+
+	methodMismatch := false
+	for _, route := range gatewayRoutes {
+		params, ok := route.match(path)
+		if !ok {
+			continue
+		}
+		if route.method != r.Method {
+			methodMismatch = true
+			continue
+		}
+		for _, q := range route.query {
+			if v := r.URL.Query().Get(q); v != "" {
+				params[q] = v
+			}
+		}
+		g.serveFunction(w, r, route.function, params)
+		return
+	}
+	if methodMismatch {
+		writeGatewayStatus(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeGatewayStatus(w, http.StatusNotFound, "not found")
+}
+
+// serveFunction calls a function with the request body and params as
+// argument, and writes its response.
+func (g *gateway) serveFunction(w http.ResponseWriter, r *http.Request, function string, params map[string]string) {
+	arg, err := gatewayArgument(r, params)
+	if err != nil {
+		writeGatewayStatus(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	payload, err := call(g.backend, function, arg)
+	if err != nil {
+		writeGatewayError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if len(payload) == 0 {
+		payload = []byte("{}")
+	}
+	w.Write(payload)
+}
+
+// gatewayArgument combines the JSON object in the request body, if any,
+// with params. Params win over body fields.
+func gatewayArgument(r *http.Request, params map[string]string) ([]byte, error) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxGatewayBodySize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(body) > maxGatewayBodySize {
+		return nil, errors.New("request body too large")
+	}
+
+	arg := map[string]interface{}{}
+	if len(bytes.TrimSpace(body)) > 0 {
+		d := json.NewDecoder(bytes.NewReader(body))
+		d.UseNumber()
+		if err = d.Decode(&arg); err != nil {
+			return nil, errors.New("request body must be a JSON object")
+		}
+	}
+	for k, v := range params {
+		arg[k] = v
+	}
+	return json.Marshal(arg)
+}
+
+func writeGatewayJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		logger.Warn(err)
+	}
+}
+
+func writeGatewayStatus(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{"error": message})
+}
+
+// writeGatewayError reports errors of the chaincode as client errors,
+// and others as failures to reach it.
+func writeGatewayError(w http.ResponseWriter, err error) {
+	logger.Warn(err)
+	if cerr, ok := err.(chaincodeError); ok {
+		status := http.StatusBadRequest
+		if strings.Contains(strings.ToLower(cerr.message), "not found") {
+			status = http.StatusNotFound
+		}
+		if cerr.message == errNotAuthorized.Error() {
+			status = http.StatusForbidden
+		}
+		writeGatewayStatus(w, status, cerr.message)
+		return
+	}
+	writeGatewayStatus(w, http.StatusBadGateway, err.Error())
+}
+
+// gatewayCommand runs the REST gateway
+func gatewayCommand(args []string) error {
+	flags := flag.NewFlagSet("gateway", flag.ContinueOnError)
+	listen := flags.String("listen", ":8080", "address to listen on")
+	serverURL := flags.String("server", "", "URL of the gateway in the OpenAPI document, defaults to http://localhost plus the listen port")
+	corsOrigin := flags.String("cors-origin", "", "origin allowed to call the gateway from a browser")
+	newBackend := backendFlags(flags, "memory")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	b, err := newBackend()
+	if err != nil {
+		return err
+	}
+	if *serverURL == "" {
+		*serverURL = "http://localhost" + (*listen)[strings.LastIndex(*listen, ":"):]
+	}
+
+	logger.Infof("gateway listening on %s", *listen)
+	return http.ListenAndServe(*listen, &gateway{
+		backend:    b,
+		serverURL:  *serverURL,
+		corsOrigin: *corsOrigin,
+	})
+}
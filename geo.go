@@ -0,0 +1,92 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"math"
+)
+
+// mean earth radius in km
+const earthRadiusKm = 6371.0
+
+func degToRad(d float64) float64 {
+	return d * math.Pi / 180
+}
+
+// haversineKm returns the great circle distance between two points in km
+func haversineKm(a, b GeoPoint) float64 {
+	lat1, lat2 := degToRad(a.Latitude), degToRad(b.Latitude)
+	dLat := lat2 - lat1
+	dLng := degToRad(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
+
+// position returns the location of a data point
+func (tdp TrackingDataPoint) position() GeoPoint {
+	return GeoPoint{Latitude: tdp.Latitude, Longitude: tdp.Longitude}
+}
+
+// insidePolygon checks whether p lies within a polygon, by counting
+// crossings of a ray from p. Coordinates are treated as planar, which
+// is fine for polygons not spanning the antimeridian or a pole.
+func insidePolygon(p GeoPoint, polygon []GeoPoint) bool {
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) &&
+			p.Longitude < (b.Longitude-a.Longitude)*(p.Latitude-a.Latitude)/(b.Latitude-a.Latitude)+a.Longitude {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// distanceToPolylineKm returns the shortest distance between p and a
+// polyline. Segments are projected onto a plane tangent at p, which is
+// precise enough for corridors of a few km.
+func distanceToPolylineKm(p GeoPoint, line []GeoPoint) float64 {
+	if len(line) == 1 {
+		return haversineKm(p, line[0])
+	}
+
+	// equirectangular projection around p, in km
+	cosLat := math.Cos(degToRad(p.Latitude))
+	project := func(q GeoPoint) (float64, float64) {
+		return degToRad(q.Longitude-p.Longitude) * cosLat * earthRadiusKm,
+			degToRad(q.Latitude-p.Latitude) * earthRadiusKm
+	}
+
+	d := math.Inf(1)
+	for i := 1; i < len(line); i++ {
+		ax, ay := project(line[i-1])
+		bx, by := project(line[i])
+
+		// closest point to origin on segment a-b
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if l := dx*dx + dy*dy; l > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/l))
+		}
+		d = math.Min(d, math.Hypot(ax+t*dx, ay+t*dy))
+	}
+	return d
+}
+
+// valid checks that a point has proper WGS84 coordinates
+func (p GeoPoint) valid() bool {
+	return p.Latitude >= -90 && p.Latitude <= 90 && p.Longitude >= -180 && p.Longitude <= 180
+}
+
+// centroid returns the mean of the vertices of a polygon, which is good
+// enough to place a marker for small, convex areas.
+func centroid(polygon []GeoPoint) GeoPoint {
+	c := GeoPoint{}
+	for _, p := range polygon {
+		c.Latitude += p.Latitude / float64(len(polygon))
+		c.Longitude += p.Longitude / float64(len(polygon))
+	}
+	return c
+}
@@ -0,0 +1,61 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+// GeoJSON (RFC 7946) data structs. Coordinates are [longitude, latitude].
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+
+	// foreign member, set if features were left out due to a limit
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+type geoJSONFeature struct {
+	Type       string                 `json:"type"`
+	Geometry   *geoJSONGeometry       `json:"geometry"` // null if location is unknown
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+func newGeoJSONFeatureCollection() geoJSONFeatureCollection {
+	return geoJSONFeatureCollection{
+		Type:     "FeatureCollection",
+		Features: []geoJSONFeature{},
+	}
+}
+
+func (fc *geoJSONFeatureCollection) add(geometry *geoJSONGeometry, properties map[string]interface{}) {
+	fc.Features = append(fc.Features, geoJSONFeature{
+		Type:       "Feature",
+		Geometry:   geometry,
+		Properties: properties,
+	})
+}
+
+func geoJSONCoordinates(p GeoPoint) []float64 {
+	return []float64{p.Longitude, p.Latitude}
+}
+
+func geoJSONPoint(p GeoPoint) *geoJSONGeometry {
+	return &geoJSONGeometry{
+		Type:        "Point",
+		Coordinates: geoJSONCoordinates(p),
+	}
+}
+
+func geoJSONLineString(points []GeoPoint) *geoJSONGeometry {
+	coordinates := make([][]float64, len(points))
+	for i, p := range points {
+		coordinates[i] = geoJSONCoordinates(p)
+	}
+	return &geoJSONGeometry{
+		Type:        "LineString",
+		Coordinates: coordinates,
+	}
+}
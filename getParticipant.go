@@ -43,7 +43,7 @@ type getIndividualParticipantInvocation struct {
 }
 
 func (inv *getIndividualParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter getIndividualParticipantInvocation.checkParseArguments")
+	loggerFor(stub).Debug("enter getIndividualParticipantInvocation.checkParseArguments")
 
 	_, args := stub.GetFunctionAndParameters()
 
@@ -54,13 +54,13 @@ func (inv *getIndividualParticipantInvocation) checkParseArguments(stub shim.Cha
 	result, err := gojsonschema.Validate(getIndividualParticipantSchemaLoader,
 		gojsonschema.NewStringLoader(args[0]))
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Warn(err)
 		return errors.New("Error parsing/validating JSON arg")
 	}
 	if !result.Valid() {
-		logger.Printf("JSON input not valid:\n")
+		loggerFor(stub).Debugf("JSON input not valid")
 		for _, err := range result.Errors() {
-			logger.Printf("- %s\n", err)
+			loggerFor(stub).Debugf("- %s", err)
 		}
 		return errors.New("JSON not valid according to schema")
 	}
@@ -68,30 +68,30 @@ func (inv *getIndividualParticipantInvocation) checkParseArguments(stub shim.Cha
 	inv.arg = getIndividualParticipantArg{}
 	err = json.Unmarshal([]byte(args[0]), &inv.arg)
 	if err != nil {
-		logger.Printf("Error unmarshaling JSON: %s", err)
+		loggerFor(stub).Debugf("Error unmarshaling JSON: %s", err)
 		return errors.New("Invalid JSON")
 	}
 	return nil
 }
 
 func (inv *getIndividualParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter getIndividualParticipant.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter getIndividualParticipant.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
 
 	ck, err := stub.CreateCompositeKey(ns, []string{".", "IndividualParticipant", "#", inv.arg.ID})
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error generating composite key (1)")
 	}
-	logger.Printf("key=%s\n", ck)
+	loggerFor(stub).Debugf("key=%s", ck)
 
 	data, err := stub.GetState(ck)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error reading from world state")
 	}
 	if data == nil {
-		logger.Println("Nothing found for given key.")
+		loggerFor(stub).Debug("Nothing found for given key.")
 		return errors.New("not found")
 	}
 
@@ -99,10 +99,10 @@ func (inv *getIndividualParticipantInvocation) process(stub shim.ChaincodeStubIn
 	// make sure data is right we're trying to unmarshal it into right type
 	err = json.Unmarshal(data, &inv.res.Participant)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error reading from world state (2)")
 	}
-	logger.Printf("Found %#v\n", inv.res.Participant)
+	loggerFor(stub).Debugf("Found %v", inv.res.Participant)
 
 	return nil
 }
@@ -0,0 +1,84 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	getParticipantSchema = `
+{
+	"$id": "PreciousCargoShippping:getParticipantSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of a participant of any kind",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"kind": {
+			"type": "string",
+			"description": "optional, to resolve IDs used by more than one kind",
+			"enum": [ "IndividualParticipant", "CorporateParticipant", "ShipmentCo" ]
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	getParticipantSchemaLoader = gojsonschema.NewStringLoader(getParticipantSchema)
+)
+
+// Retrieves a participant of any kind by Id
+type getParticipantArg struct {
+	ID   string `json:"id"`
+	Kind string `json:"kind"`
+}
+
+// Returns kind and data of the participant
+type getParticipantResult struct {
+	Kind        string      `json:"kind"`
+	Participant interface{} `json:"participant"`
+}
+
+type getParticipantInvocation struct {
+	arg getParticipantArg
+	res getParticipantResult
+}
+
+func (inv *getParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getParticipantInvocation.checkParseArguments")
+
+	inv.arg = getParticipantArg{}
+	return parseArgument(stub, getParticipantSchemaLoader, &inv.arg)
+}
+
+func (inv *getParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getParticipantInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	kinds := []string{}
+	if inv.arg.Kind != "" {
+		kinds = append(kinds, inv.arg.Kind)
+	}
+	kind, x, err := getAnyParticipant(stub, inv.arg.ID, kinds...)
+	if err == errNotFound {
+		return errors.New("not found")
+	}
+	if err != nil {
+		return err
+	}
+	inv.res = getParticipantResult{
+		Kind:        kind,
+		Participant: x,
+	}
+
+	return nil
+}
+
+func (inv *getParticipantInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,69 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	getContainerSchema = `
+{
+	"$id": "PreciousCargoShippping:getContainerSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Container"
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	getContainerSchemaLoader = gojsonschema.NewStringLoader(getContainerSchema)
+)
+
+// Retrieves a container and the history of its seals by Id
+type getContainerArg struct {
+	ID string `json:"id"`
+}
+
+type getContainerResult struct {
+	Container  Container   `json:"container"`
+	SealChecks []SealCheck `json:"sealChecks"`
+}
+
+type getContainerInvocation struct {
+	arg getContainerArg
+	res getContainerResult
+}
+
+func (inv *getContainerInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getContainerInvocation.checkParseArguments")
+
+	inv.arg = getContainerArg{}
+	return parseArgument(stub, getContainerSchemaLoader, &inv.arg)
+}
+
+func (inv *getContainerInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getContainerInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	c, err := getContainer(stub, inv.arg.ID)
+	if err != nil {
+		return err
+	}
+	inv.res = getContainerResult{
+		Container:  *c,
+		SealChecks: []SealCheck{},
+	}
+	return scanSealChecks(stub, inv.arg.ID, func(sc SealCheck) error {
+		inv.res.SealChecks = append(inv.res.SealChecks, sc)
+		return nil
+	})
+}
+
+func (inv *getContainerInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
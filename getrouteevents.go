@@ -0,0 +1,79 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	getRouteEventsSchema = `
+{
+	"$id": "PreciousCargoShippping:getRouteEventsSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	getRouteEventsSchemaLoader = gojsonschema.NewStringLoader(getRouteEventsSchema)
+)
+
+// Retrieves the planned route of a shipment and the geofence events
+// recorded so far
+type getRouteEventsArg struct {
+	ID string `json:"id"`
+}
+
+type getRouteEventsResult struct {
+	ID     string          `json:"id"`
+	Route  *PlannedRoute   `json:"route"`
+	State  *RouteState     `json:"state"`
+	Events []GeofenceEvent `json:"events"`
+}
+
+type getRouteEventsInvocation struct {
+	arg getRouteEventsArg
+	res getRouteEventsResult
+}
+
+func (inv *getRouteEventsInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getRouteEventsInvocation.checkParseArguments")
+
+	inv.arg = getRouteEventsArg{}
+	return parseArgument(stub, getRouteEventsSchemaLoader, &inv.arg)
+}
+
+func (inv *getRouteEventsInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getRouteEventsInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	shipment := x.(*Shipment)
+
+	inv.res = getRouteEventsResult{
+		ID:     inv.arg.ID,
+		Route:  shipment.Route,
+		State:  shipment.RouteState,
+		Events: []GeofenceEvent{},
+	}
+	return scanGeofenceEvents(stub, inv.arg.ID, func(ev GeofenceEvent) error {
+		inv.res.Events = append(inv.res.Events, ev)
+		return nil
+	})
+}
+
+func (inv *getRouteEventsInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
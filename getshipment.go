@@ -31,9 +31,12 @@ type getShipmentArg struct {
 	ID string `json:"id"`
 }
 
-// Returns ID of shipment
+// Returns shipment, with status of its parent and children if
+// it has been split or consolidated
 type getShipmentResult struct {
-	Shipment Shipment `json:"participant"`
+	Shipment Shipment      `json:"participant"`
+	Parent   *shipmentRef  `json:"parentShipment,omitempty"`
+	Children []shipmentRef `json:"childShipments,omitempty"`
 }
 
 type getShipmentInvocation struct {
@@ -42,7 +45,7 @@ type getShipmentInvocation struct {
 }
 
 func (inv *getShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter getShipmentInvocation.checkParseArguments")
+	loggerFor(stub).Debug("enter getShipmentInvocation.checkParseArguments")
 
 	_, args := stub.GetFunctionAndParameters()
 
@@ -58,9 +61,9 @@ func (inv *getShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInt
 		return errors.New("error parsing/validating JSON arg")
 	}
 	if !result.Valid() {
-		logger.Printf("JSON input not valid:\n")
+		loggerFor(stub).Debugf("JSON input not valid")
 		for _, err := range result.Errors() {
-			logger.Printf("- %s\n", err)
+			loggerFor(stub).Debugf("- %s", err)
 		}
 		return errors.New("json not valid according to schema")
 	}
@@ -68,15 +71,15 @@ func (inv *getShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInt
 	inv.arg = getShipmentArg{}
 	err = json.Unmarshal([]byte(args[0]), &inv.arg)
 	if err != nil {
-		logger.Printf("error unmarshaling JSON: %s", err)
+		loggerFor(stub).Debugf("error unmarshaling JSON: %s", err)
 		return errors.New("Invalid JSON")
 	}
 	return nil
 }
 
 func (inv *getShipmentInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter submitShipmentInvocation.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter submitShipmentInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
 
 	r := &registry{
 		typeStr: "Shipment",
@@ -84,9 +87,26 @@ func (inv *getShipmentInvocation) process(stub shim.ChaincodeStubInterface) erro
 	}
 
 	_, x, err := r.get(stub, inv.arg.ID)
-	inv.res = x.(getShipmentResult)
+	if err != nil {
+		return err
+	}
+	inv.res.Shipment = *x.(*Shipment)
+
+	if inv.res.Shipment.ParentID != "" {
+		refs, err := getShipmentRefs(stub, []string{inv.res.Shipment.ParentID})
+		if err != nil {
+			return err
+		}
+		inv.res.Parent = &refs[0]
+	}
+	if len(inv.res.Shipment.ChildIDs) > 0 {
+		inv.res.Children, err = getShipmentRefs(stub, inv.res.Shipment.ChildIDs)
+		if err != nil {
+			return err
+		}
+	}
 
-	return err
+	return nil
 }
 
 func (inv *getShipmentInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
@@ -0,0 +1,295 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	getShipmentTrackGeoJSONSchema = `
+{
+	"$id": "PreciousCargoShippping:getShipmentTrackGeoJSONSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		},
+		"from": {
+			"type": "string",
+			"description": "start of time range (inclusive) in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		},
+		"to": {
+			"type": "string",
+			"description": "end of time range (exclusive) in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		},
+		"minTemp": { "type": "number", "description": "readings below are temperature excursions" },
+		"maxTemp": { "type": "number", "description": "readings above are temperature excursions" },
+		"minHum": { "type": "number", "minimum": 0, "maximum": 100, "description": "readings below are humidity excursions" },
+		"maxHum": { "type": "number", "minimum": 0, "maximum": 100, "description": "readings above are humidity excursions" }
+	},
+	"required": [ "id" ]
+}
+`
+	getShipmentTrackGeoJSONSchemaLoader = gojsonschema.NewStringLoader(getShipmentTrackGeoJSONSchema)
+)
+
+// Retrieves the track of a shipment as GeoJSON, by Id, optionally within
+// a time range. Readings outside of the optional limits are marked as
+// excursions. Like getTrackingData, it returns up to maxTrackingDataPoints
+// readings and marks the collection as truncated if there are more.
+type getShipmentTrackGeoJSONArg struct {
+	ID   string `json:"id"`
+	From string `json:"from,omitempty"`
+	To   string `json:"to,omitempty"`
+
+	MinTemperature *float32 `json:"minTemp,omitempty"`
+	MaxTemperature *float32 `json:"maxTemp,omitempty"`
+	MinHumidity    *float32 `json:"minHum,omitempty"`
+	MaxHumidity    *float32 `json:"maxHum,omitempty"`
+}
+
+// causes of excursions
+const (
+	excursionTemperature = "temperature"
+	excursionHumidity    = "humidity"
+	excursionOffRoute    = "offRoute"
+)
+
+type getShipmentTrackGeoJSONInvocation struct {
+	arg getShipmentTrackGeoJSONArg
+
+	from, to time.Time
+	shipment *Shipment
+
+	// result is a GeoJSON FeatureCollection
+	res geoJSONFeatureCollection
+}
+
+func (inv *getShipmentTrackGeoJSONInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getShipmentTrackGeoJSONInvocation.checkParseArguments")
+
+	inv.arg = getShipmentTrackGeoJSONArg{}
+	err := parseArgument(stub, getShipmentTrackGeoJSONSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.from, inv.to, err = parseTimeRange(inv.arg.From, inv.arg.To)
+	if err != nil {
+		return err
+	}
+	if inv.arg.MinTemperature != nil && inv.arg.MaxTemperature != nil && *inv.arg.MinTemperature > *inv.arg.MaxTemperature {
+		return errors.New("invalid maxTemp argument: Below minTemp")
+	}
+	if inv.arg.MinHumidity != nil && inv.arg.MaxHumidity != nil && *inv.arg.MinHumidity > *inv.arg.MaxHumidity {
+		return errors.New("invalid maxHum argument: Below minHum")
+	}
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	inv.shipment = x.(*Shipment)
+
+	return nil
+}
+
+// process builds a feature collection of
+//   - the sender and recipient as origin and destination markers,
+//   - the track as a LineString, with time, temperature and humidity of
+//     each point as arrays in the properties,
+//   - temperature, humidity and off route excursions as points,
+//   - handovers between carriers as points,
+//
+// with the track, excursions and handovers limited to the time range.
+func (inv *getShipmentTrackGeoJSONInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getShipmentTrackGeoJSONInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	s := inv.shipment
+	inv.res = newGeoJSONFeatureCollection()
+
+	var origin, destination *Geofence
+	if s.Route != nil {
+		origin, destination = s.Route.Origin, s.Route.Destination
+	}
+	inv.addParticipantMarker(stub, "origin", s.FromID, origin)
+	inv.addParticipantMarker(stub, "destination", s.ToID, destination)
+
+	positions := []GeoPoint{}
+	times := []time.Time{}
+	temperatures := []float32{}
+	humidities := []float32{}
+	err := scanTrackingDataPoints(stub, s.ID.ID, inv.from, inv.to, func(tdp TrackingDataPoint) error {
+		if len(positions) == maxTrackingDataPoints {
+			// leave out everything from the first point not returned on
+			inv.to = tdp.At
+			return errTooManyDataPoints
+		}
+		positions = append(positions, tdp.position())
+		times = append(times, tdp.At)
+		temperatures = append(temperatures, tdp.Temperature)
+		humidities = append(humidities, tdp.Humidity)
+		return nil
+	})
+	if err == errTooManyDataPoints {
+		inv.res.Truncated = true
+	} else if err != nil {
+		return err
+	}
+	if len(positions) > 0 {
+		inv.res.add(geoJSONLineString(positions), map[string]interface{}{
+			"kind":       "track",
+			"shipmentId": s.ID.ID,
+			"times":      times,
+			"temp":       temperatures,
+			"hum":        humidities,
+		})
+	}
+	inv.addExcursions(excursionTemperature, positions, times, temperatures, inv.arg.MinTemperature, inv.arg.MaxTemperature)
+	inv.addExcursions(excursionHumidity, positions, times, humidities, inv.arg.MinHumidity, inv.arg.MaxHumidity)
+
+	// custody changes whenever a leg departs after the first one
+	for i := 1; i < len(s.Legs); i++ {
+		leg := s.Legs[i]
+		if leg.ActualDeparture == nil || !inv.within(*leg.ActualDeparture) {
+			continue
+		}
+		var geometry *geoJSONGeometry
+		if pos, ok := positionAt(positions, times, *leg.ActualDeparture); ok {
+			geometry = geoJSONPoint(pos)
+		}
+		inv.res.add(geometry, map[string]interface{}{
+			"kind":        "handover",
+			"shipmentId":  s.ID.ID,
+			"at":          *leg.ActualDeparture,
+			"hub":         leg.OriginHub,
+			"fromCarrier": s.Legs[i-1].CarrierID,
+			"toCarrier":   leg.CarrierID,
+		})
+	}
+
+	return scanGeofenceEvents(stub, s.ID.ID, func(ev GeofenceEvent) error {
+		if ev.Type != RouteOffRoute || !inv.within(ev.At) {
+			return nil
+		}
+		inv.res.add(geoJSONPoint(ev.Position), map[string]interface{}{
+			"kind":       "excursion",
+			"cause":      excursionOffRoute,
+			"shipmentId": s.ID.ID,
+			"at":         ev.At,
+			"device":     ev.DeviceID,
+		})
+		return nil
+	})
+}
+
+// within tells whether a point in time is in the requested time range
+func (inv *getShipmentTrackGeoJSONInvocation) within(t time.Time) bool {
+	return (inv.from.IsZero() || !t.Before(inv.from)) && (inv.to.IsZero() || t.Before(inv.to))
+}
+
+// addParticipantMarker adds a point for the sender or recipient. Anyone
+// may query the track, so markers leave out names and addresses. The
+// marker is placed at the coordinates of a corporate participant's
+// address if known, else at the origin or destination of the planned route
+// if there is one, and has no geometry otherwise. Addresses of individuals
+// are personal data, their coordinates are not used.
+func (inv *getShipmentTrackGeoJSONInvocation) addParticipantMarker(stub shim.ChaincodeStubInterface, kind string, participantID string, area *Geofence) {
+	properties := map[string]interface{}{
+		"kind":          kind,
+		"participantId": participantID,
+	}
+	var geometry *geoJSONGeometry
+	if area != nil {
+		geometry = geoJSONPoint(centroid(area.Polygon))
+	}
+
+	participantKind, x, err := getAnyParticipant(stub, participantID, senderRecipientKinds...)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+	} else {
+		properties["participantKind"] = participantKind
+		if p, ok := x.(*CorporateParticipant); ok && p.Address.Geo != nil {
+			geometry = geoJSONPoint(*p.Address.Geo)
+		}
+	}
+
+	inv.res.add(geometry, properties)
+}
+
+// addExcursions adds a point for each run of readings outside of a range,
+// at the first reading of the run. Its properties tell when the run ended
+// and the value farthest outside. Nil limits are not checked.
+func (inv *getShipmentTrackGeoJSONInvocation) addExcursions(cause string, positions []GeoPoint, times []time.Time, values []float32, min, max *float32) {
+	deviation := func(v float32) float32 {
+		switch {
+		case min != nil && v < *min:
+			return *min - v
+		case max != nil && v > *max:
+			return v - *max
+		}
+		return 0
+	}
+
+	start := -1
+	for i := 0; i <= len(values); i++ {
+		outside := i < len(values) && deviation(values[i]) > 0
+		if outside && start < 0 {
+			start = i
+		}
+		if outside || start < 0 {
+			continue
+		}
+
+		peak := values[start]
+		for _, v := range values[start:i] {
+			if deviation(v) > deviation(peak) {
+				peak = v
+			}
+		}
+		properties := map[string]interface{}{
+			"kind":       "excursion",
+			"cause":      cause,
+			"shipmentId": inv.shipment.ID.ID,
+			"at":         times[start],
+			"until":      times[i-1],
+			"peak":       peak,
+		}
+		if min != nil {
+			properties["min"] = *min
+		}
+		if max != nil {
+			properties["max"] = *max
+		}
+		inv.res.add(geoJSONPoint(positions[start]), properties)
+		start = -1
+	}
+}
+
+func (inv *getShipmentTrackGeoJSONInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
+
+// positionAt returns the last tracked position at or before t, or the first
+// one if t is before all positions. times must be sorted.
+func positionAt(positions []GeoPoint, times []time.Time, t time.Time) (GeoPoint, bool) {
+	if len(positions) == 0 {
+		return GeoPoint{}, false
+	}
+	idx := 0
+	for i, at := range times {
+		if at.After(t) {
+			break
+		}
+		idx = i
+	}
+	return positions[idx], true
+}
@@ -0,0 +1,133 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maximum number of data points returned by getTrackingData
+const maxTrackingDataPoints = 1000
+
+var (
+	getTrackingDataSchema = `
+{
+	"$id": "PreciousCargoShippping:getTrackingDataSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		},
+		"from": {
+			"type": "string",
+			"description": "start of time range (inclusive) in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		},
+		"to": {
+			"type": "string",
+			"description": "end of time range (exclusive) in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	getTrackingDataSchemaLoader = gojsonschema.NewStringLoader(getTrackingDataSchema)
+
+	errTooManyDataPoints = errors.New("too many data points")
+)
+
+// Retrieves tracking data of a shipment, optionally within a time range
+type getTrackingDataArg struct {
+	ID   string `json:"id"`
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// Returns data points in order of measurement time. Truncated is set if
+// there are more than maxTrackingDataPoints, so the range should be narrowed.
+type getTrackingDataResult struct {
+	ID        string              `json:"id"`
+	Points    []TrackingDataPoint `json:"points"`
+	Truncated bool                `json:"truncated,omitempty"`
+}
+
+type getTrackingDataInvocation struct {
+	arg getTrackingDataArg
+
+	from, to time.Time
+
+	res getTrackingDataResult
+}
+
+// parseTimeRange parses the optional from and to arguments of a query
+// for tracking data. Missing ones are returned as zero times.
+func parseTimeRange(fromArg, toArg string) (from, to time.Time, err error) {
+	if fromArg != "" {
+		from, err = time.Parse(time.RFC3339, fromArg)
+		if err != nil {
+			return from, to, errors.New("invalid from argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
+		}
+	}
+	if toArg != "" {
+		to, err = time.Parse(time.RFC3339, toArg)
+		if err != nil {
+			return from, to, errors.New("invalid to argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
+		}
+	}
+	if !from.IsZero() && !to.IsZero() && !from.Before(to) {
+		return from, to, errors.New("invalid time range: from must be before to")
+	}
+	return from, to, nil
+}
+
+func (inv *getTrackingDataInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getTrackingDataInvocation.checkParseArguments")
+
+	inv.arg = getTrackingDataArg{}
+	err := parseArgument(stub, getTrackingDataSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.from, inv.to, err = parseTimeRange(inv.arg.From, inv.arg.To)
+	if err != nil {
+		return err
+	}
+
+	if _, _, err = shipmentRegistry().get(stub, inv.arg.ID); err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+
+	return nil
+}
+
+func (inv *getTrackingDataInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getTrackingDataInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	inv.res = getTrackingDataResult{
+		ID:     inv.arg.ID,
+		Points: []TrackingDataPoint{},
+	}
+	err := scanTrackingDataPoints(stub, inv.arg.ID, inv.from, inv.to, func(tdp TrackingDataPoint) error {
+		if len(inv.res.Points) == maxTrackingDataPoints {
+			return errTooManyDataPoints
+		}
+		inv.res.Points = append(inv.res.Points, tdp)
+		return nil
+	})
+	if err == errTooManyDataPoints {
+		inv.res.Truncated = true
+		return nil
+	}
+	return err
+}
+
+func (inv *getTrackingDataInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,75 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	getTrackingSummarySchema = `
+{
+	"$id": "PreciousCargoShippping:getTrackingSummarySchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	getTrackingSummarySchemaLoader = gojsonschema.NewStringLoader(getTrackingSummarySchema)
+)
+
+// Retrieves aggregated tracking data of a shipment by Id
+type getTrackingSummaryArg struct {
+	ID string `json:"id"`
+}
+
+// Returns the summary, which is empty if nothing has been tracked yet
+type getTrackingSummaryResult struct {
+	ID      string          `json:"id"`
+	Summary TrackingSummary `json:"summary"`
+}
+
+type getTrackingSummaryInvocation struct {
+	arg getTrackingSummaryArg
+	res getTrackingSummaryResult
+}
+
+func (inv *getTrackingSummaryInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getTrackingSummaryInvocation.checkParseArguments")
+
+	inv.arg = getTrackingSummaryArg{}
+	return parseArgument(stub, getTrackingSummarySchemaLoader, &inv.arg)
+}
+
+func (inv *getTrackingSummaryInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter getTrackingSummaryInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	shipment := x.(*Shipment)
+
+	inv.res = getTrackingSummaryResult{
+		ID: inv.arg.ID,
+	}
+	if shipment.Summary != nil {
+		inv.res.Summary = *shipment.Summary
+	}
+
+	return nil
+}
+
+func (inv *getTrackingSummaryInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,263 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"reflect"
+)
+
+// Roles required to call a function, as listed by describe. Admins may
+// call all functions.
+const (
+	roleAnyone           = "anyone"
+	roleParticipant      = "participant"      // the participant itself
+	roleOwner            = "owner"            // the ShipmentCo owning the device or container
+	roleShipper          = "shipper"          // the shipper of the shipments
+	roleCarrier          = "carrier"          // the carrier of the leg
+	roleCustodian        = "custodian"        // the carrier having custody of the shipment
+	roleContainerHandler = "containerHandler" // the container's owner or a carrier of its shipments
+)
+
+// handlerInfo describes a chaincode function
+type handlerInfo struct {
+	// implementation type, an InvocationHandler
+	typ reflect.Type
+
+	description string
+
+	// JSON schema of the argument, if there is a handwritten one
+	schema string
+
+	role string
+
+	// functions changing state are transactions, others are queries and
+	// get a stub refusing writes
+	mutates bool
+}
+
+func handlerType(inv InvocationHandler) reflect.Type {
+	return reflect.TypeOf(inv).Elem()
+}
+
+// handlers maps function names to their implementations
+var handlers = map[string]handlerInfo{
+	"submitShipment": {
+		typ:         handlerType(&submitShipmentInvocation{}),
+		description: "Submits a shipment from a sender to a recipient, carried by a shipper and optionally further carriers.",
+		schema:      submitShipmentSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"getShipment": {
+		typ:         handlerType(&getShipmentInvocation{}),
+		description: "Returns a shipment with the status of its parent and children.",
+		schema:      getShipmentSchema,
+		role:        roleAnyone,
+	},
+	"findShipments": {
+		typ:         handlerType(&findShipmentsInvocation{}),
+		description: "Finds shipments by shipper, sender, recipient, carrier and/or status, page by page.",
+		schema:      findShipmentsSchema,
+		role:        roleAnyone,
+	},
+	"queryShipments": {
+		typ:         handlerType(&queryShipmentsInvocation{}),
+		description: "Searches shipments by a restricted Mango selector. Needs CouchDB as state database.",
+		schema:      queryShipmentsSchema,
+		role:        roleAnyone,
+	},
+	"registerIndividualParticipant": {
+		typ:         handlerType(&registerIndividualParticipantInvocation{}),
+		description: "Registers a person. Only admins may register duplicates.",
+		schema:      registerIndividualParticipantSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"getIndividualParticipant": {
+		typ:         handlerType(&getIndividualParticipantInvocation{}),
+		description: "Returns a person.",
+		schema:      getIndividualParticipantSchema,
+		role:        roleAnyone,
+	},
+	"registerShipmentCo": {
+		typ:         handlerType(&registerShipmentCoInvocation{}),
+		description: "Registers a shipment company. Only admins may register duplicates.",
+		schema:      registerShipmentCoSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"registerCorporateParticipant": {
+		typ:         handlerType(&registerCorporateParticipantInvocation{}),
+		description: "Registers a company sending or receiving shipments. Only admins may register duplicates.",
+		schema:      registerCorporateParticipantSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"bulkRegisterParticipants": {
+		typ:         handlerType(&bulkRegisterParticipantsInvocation{}),
+		description: "Registers participants of any kind, reporting on each. Only admins may register duplicates.",
+		schema:      bulkRegisterParticipantsSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"getParticipant": {
+		typ:         handlerType(&getParticipantInvocation{}),
+		description: "Returns a participant of any kind.",
+		schema:      getParticipantSchema,
+		role:        roleAnyone,
+	},
+	"updateIndividualParticipant": {
+		typ:         handlerType(&updateIndividualParticipantInvocation{}),
+		description: "Changes name and/or address of a person.",
+		schema:      updateIndividualParticipantSchema,
+		role:        roleParticipant,
+		mutates:     true,
+	},
+	"updateShipmentCo": {
+		typ:         handlerType(&updateShipmentCoInvocation{}),
+		description: "Changes name and/or address of a shipment company.",
+		schema:      updateShipmentCoSchema,
+		role:        roleParticipant,
+		mutates:     true,
+	},
+	"deactivateParticipant": {
+		typ:         handlerType(&deactivateParticipantInvocation{}),
+		description: "Retires a participant of any kind.",
+		schema:      deactivateParticipantSchema,
+		role:        roleParticipant,
+		mutates:     true,
+	},
+	"trackShipment": {
+		typ:         handlerType(&trackShipmentInvocation{}),
+		description: "Records a reading of a tracking device assigned to a shipment.",
+		schema:      trackShipmentSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"trackShipmentBatch": {
+		typ:         handlerType(&trackShipmentBatchInvocation{}),
+		description: "Records readings of tracking devices assigned to a shipment, reporting on each.",
+		schema:      trackShipmentBatchSchema,
+		role:        roleAnyone,
+		mutates:     true,
+	},
+	"getTrackingData": {
+		typ:         handlerType(&getTrackingDataInvocation{}),
+		description: "Returns the readings of a shipment within a time range.",
+		schema:      getTrackingDataSchema,
+		role:        roleAnyone,
+	},
+	"getTrackingSummary": {
+		typ:         handlerType(&getTrackingSummaryInvocation{}),
+		description: "Returns aggregated readings of a shipment.",
+		schema:      getTrackingSummarySchema,
+		role:        roleAnyone,
+	},
+	"getRouteEvents": {
+		typ:         handlerType(&getRouteEventsInvocation{}),
+		description: "Returns geofence and route events of a shipment.",
+		schema:      getRouteEventsSchema,
+		role:        roleAnyone,
+	},
+	"getShipmentTrackGeoJSON": {
+		typ:         handlerType(&getShipmentTrackGeoJSONInvocation{}),
+		description: "Returns the track of a shipment as GeoJSON feature collection.",
+		schema:      getShipmentTrackGeoJSONSchema,
+		role:        roleAnyone,
+	},
+	"updateLegStatus": {
+		typ:         handlerType(&updateLegStatusInvocation{}),
+		description: "Records departure or arrival of a leg.",
+		schema:      updateLegStatusSchema,
+		role:        roleCarrier,
+		mutates:     true,
+	},
+	"splitShipment": {
+		typ:         handlerType(&splitShipmentInvocation{}),
+		description: "Splits a shipment into parts, which continue on their own.",
+		schema:      splitShipmentSchema,
+		role:        roleCustodian,
+		mutates:     true,
+	},
+	"consolidateShipments": {
+		typ:         handlerType(&consolidateShipmentsInvocation{}),
+		description: "Consolidates shipments into a new one, which carries them on.",
+		schema:      consolidateShipmentsSchema,
+		role:        roleShipper,
+		mutates:     true,
+	},
+	"registerContainer": {
+		typ:         handlerType(&registerContainerInvocation{}),
+		description: "Registers a container of a shipment company.",
+		schema:      registerContainerSchema,
+		role:        roleOwner,
+		mutates:     true,
+	},
+	"loadContainer": {
+		typ:         handlerType(&loadContainerInvocation{}),
+		description: "Loads a shipment into a container.",
+		schema:      loadContainerSchema,
+		role:        roleCustodian,
+		mutates:     true,
+	},
+	"sealContainer": {
+		typ:         handlerType(&sealContainerInvocation{}),
+		description: "Seals a container.",
+		schema:      sealContainerSchema,
+		role:        roleContainerHandler,
+		mutates:     true,
+	},
+	"inspectSeal": {
+		typ:         handlerType(&inspectSealInvocation{}),
+		description: "Records an inspection of a container seal, flagging shipments if it is not intact.",
+		schema:      inspectSealSchema,
+		role:        roleContainerHandler,
+		mutates:     true,
+	},
+	"breakSeal": {
+		typ:         handlerType(&breakSealInvocation{}),
+		description: "Records the intended breaking of a container seal.",
+		schema:      breakSealSchema,
+		role:        roleContainerHandler,
+		mutates:     true,
+	},
+	"getContainer": {
+		typ:         handlerType(&getContainerInvocation{}),
+		description: "Returns a container with its seal checks.",
+		schema:      getContainerSchema,
+		role:        roleAnyone,
+	},
+	"registerDevice": {
+		typ:         handlerType(&registerDeviceInvocation{}),
+		description: "Registers a tracking device of a shipment company.",
+		schema:      registerDeviceSchema,
+		role:        roleOwner,
+		mutates:     true,
+	},
+	"revokeDevice": {
+		typ:         handlerType(&revokeDeviceInvocation{}),
+		description: "Revokes a tracking device, its readings are refused from then on.",
+		schema:      revokeDeviceSchema,
+		role:        roleOwner,
+		mutates:     true,
+	},
+	"getConfig": {
+		typ:         handlerType(&getConfigInvocation{}),
+		description: "Returns the configuration of the chaincode.",
+		schema:      getConfigSchema,
+		role:        roleAnyone,
+	},
+	"setConfig": {
+		typ:         handlerType(&setConfigInvocation{}),
+		description: "Replaces the configuration of the chaincode, e.g. log level and format. Admins only.",
+		schema:      setConfigSchema,
+		role:        roleAdmin,
+		mutates:     true,
+	},
+	"exportState": {
+		typ:         handlerType(&exportStateInvocation{}),
+		description: "Exports all keys of the chaincode with their decoded values page by page, each page chained to the previous one by its hash. Admins only.",
+		schema:      exportStateSchema,
+		role:        roleAdmin,
+	},
+}
@@ -0,0 +1,110 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Shipments form a hierarchy when split or consolidated. Only one level of
+// it is active at a time: a split shipment is continued by its parts, a
+// consolidated shipment by the shipment it is contained in. Tracking data
+// and leg status go to the active shipment only, so custody is never
+// recorded twice.
+
+// shipmentRef identifies a related shipment and its status
+type shipmentRef struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// checkActive makes sure a shipment is not continued by its children or
+// its parent.
+func (s *Shipment) checkActive() error {
+	switch s.Status {
+	case ShipmentSplit:
+		return fmt.Errorf("shipment %s has been split into %v", s.ID.ID, s.ChildIDs)
+	case ShipmentConsolidated:
+		return fmt.Errorf("shipment %s has been consolidated into %s", s.ID.ID, s.ParentID)
+	}
+	return nil
+}
+
+// custodian returns the ID of the carrier having custody at the given time
+func (s *Shipment) custodian(t time.Time) string {
+	return s.Legs[s.legAt(t)].CarrierID
+}
+
+// checkCallerHasCustody makes sure the caller is the carrier having custody
+// of a shipment at the given time, or an admin.
+func checkCallerHasCustody(stub shim.ChaincodeStubInterface, s *Shipment, t time.Time) error {
+	s.ensureLegs()
+
+	_, x, err := shipmentCoRegistry().get(stub, s.custodian(t))
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate carrier having custody")
+	}
+	return checkCallerIs(stub, x.(*ShipmentCo).Participant)
+}
+
+// getShipmentRefs loads the status of related shipments
+func getShipmentRefs(stub shim.ChaincodeStubInterface, ids []string) ([]shipmentRef, error) {
+	refs := []shipmentRef{}
+	for _, id := range ids {
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return nil, fmt.Errorf("unable to locate related shipment %s", id)
+		}
+		refs = append(refs, shipmentRef{ID: id, Status: x.(*Shipment).Status})
+	}
+	return refs, nil
+}
+
+// releaseChildren marks the shipments contained in a delivered consolidated
+// shipment as delivered, too.
+func releaseChildren(stub shim.ChaincodeStubInterface, ev *eventRecorder, parent *Shipment) error {
+	if parent.Status != ShipmentDelivered {
+		return nil
+	}
+	for _, id := range parent.ChildIDs {
+		_, x, err := shipmentRegistry().get(stub, id)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return fmt.Errorf("unable to locate contained shipment %s", id)
+		}
+		child := x.(*Shipment)
+		if child.Status != ShipmentConsolidated {
+			continue
+		}
+		child.Status = ShipmentDelivered
+		child.DelivererAt = parent.DelivererAt
+		if _, err = saveShipment(stub, ev, child); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeManifests sums up quantities by SKU, keeping the order of first
+// appearance.
+func mergeManifests(manifests ...[]ManifestItem) []ManifestItem {
+	res := []ManifestItem{}
+	idx := map[string]int{}
+	for _, manifest := range manifests {
+		for _, item := range manifest {
+			if i, found := idx[item.SKU]; found {
+				res[i].Quantity += item.Quantity
+				continue
+			}
+			idx[item.SKU] = len(res)
+			res = append(res, item)
+		}
+	}
+	return res
+}
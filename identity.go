@@ -0,0 +1,61 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/hyperledger/fabric/core/chaincode/shim/ext/cid"
+)
+
+// Callers with this attribute value in their certificate are admins
+const (
+	roleAttribute = "pcs.role"
+	roleAdmin     = "admin"
+)
+
+var errNotAuthorized = errors.New("not authorized")
+
+// callerID returns the unique ID of the caller's certificate
+func callerID(stub shim.ChaincodeStubInterface) (string, error) {
+	ci, err := cid.New(stub)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal error reading caller identity")
+	}
+	id, err := ci.GetID()
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal error reading caller identity")
+	}
+	return id, nil
+}
+
+// callerIsAdmin checks the role attribute of the caller's certificate
+func callerIsAdmin(stub shim.ChaincodeStubInterface) bool {
+	ci, err := cid.New(stub)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return false
+	}
+	return ci.AssertAttributeValue(roleAttribute, roleAdmin) == nil
+}
+
+// checkCallerIs makes sure the caller is the participant, i.e. uses the
+// identity the participant registered with, or is an admin. Participants
+// registered without an identity can only be managed by admins.
+func checkCallerIs(stub shim.ChaincodeStubInterface, p Participant) error {
+	if callerIsAdmin(stub) {
+		return nil
+	}
+	id, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+	if p.Identity == "" || p.Identity != id {
+		loggerFor(stub).Infof("caller is not participant %s", p.ID.ID)
+		return errNotAuthorized
+	}
+	return nil
+}
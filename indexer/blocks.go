@@ -0,0 +1,177 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// blockSource is the checkpoint of block files, the number of the last
+// block processed completely.
+const blockSource = "blocks"
+
+// block is the part of a block the indexer needs
+type block struct {
+	number uint64
+	txs    []txRecord
+}
+
+// indexBlockFiles processes block files, as fetched by
+// 'peer channel fetch <number> <file>', in order of block number.
+// Blocks up to the checkpoint are skipped.
+func indexBlockFiles(st *store, files []string, eventName string) error {
+	blocks := []block{}
+	for _, file := range files {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		b, err := parseBlock(data, eventName)
+		if err != nil {
+			return fmt.Errorf("%s: %s", file, err)
+		}
+		blocks = append(blocks, b)
+	}
+	sort.Slice(blocks, func(i, j int) bool { return blocks[i].number < blocks[j].number })
+
+	last, found, err := st.checkpoint(blockSource)
+	if err != nil {
+		return err
+	}
+	applied, skipped := 0, 0
+	for _, b := range blocks {
+		n := int64(b.number)
+		if found && n <= last {
+			continue
+		}
+		if found && n != last+1 {
+			logger.Printf("blocks %d to %d are missing, continuing with block %d", last+1, n-1, n)
+		}
+		// a block interrupted half way is processed again, its
+		// transactions processed already are skipped
+		for _, rec := range b.txs {
+			ok, err := st.apply(blockSource, n, rec, false)
+			if err != nil {
+				return fmt.Errorf("block %d: %s", n, err)
+			}
+			if ok {
+				applied++
+			} else {
+				skipped++
+			}
+		}
+		if err = st.setCheckpoint(blockSource, n); err != nil {
+			return err
+		}
+		last, found = n, true
+	}
+	logger.Printf("processed %d transactions, skipped %d seen before, last block is %d", applied, skipped, last)
+	return nil
+}
+
+// parseBlock extracts the chaincode events of the given name from the
+// valid endorser transactions of a block.
+func parseBlock(data []byte, eventName string) (block, error) {
+	var res block
+	b := &common.Block{}
+	if err := proto.Unmarshal(data, b); err != nil {
+		return res, fmt.Errorf("invalid block: %s", err)
+	}
+	if b.Header == nil || b.Data == nil {
+		return res, fmt.Errorf("invalid block: No header or data")
+	}
+	res.number = b.Header.Number
+
+	// validation codes of the transactions, by index
+	var filter []byte
+	if b.Metadata != nil && len(b.Metadata.Metadata) > int(common.BlockMetadataIndex_TRANSACTIONS_FILTER) {
+		filter = b.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER]
+	}
+
+	for i, envBytes := range b.Data.Data {
+		if i < len(filter) && pb.TxValidationCode(filter[i]) != pb.TxValidationCode_VALID {
+			continue
+		}
+		rec, err := parseTransaction(envBytes, eventName)
+		if err != nil {
+			return res, fmt.Errorf("transaction %d of block %d: %s", i, res.number, err)
+		}
+		if rec != nil {
+			res.txs = append(res.txs, *rec)
+		}
+	}
+	return res, nil
+}
+
+// parseTransaction returns the events of an endorser transaction, or
+// nil if it is none or has no chaincode event of the given name.
+func parseTransaction(envBytes []byte, eventName string) (*txRecord, error) {
+	env := &common.Envelope{}
+	if err := proto.Unmarshal(envBytes, env); err != nil {
+		return nil, err
+	}
+	payload := &common.Payload{}
+	if err := proto.Unmarshal(env.Payload, payload); err != nil {
+		return nil, err
+	}
+	if payload.Header == nil {
+		return nil, fmt.Errorf("no header")
+	}
+	chdr := &common.ChannelHeader{}
+	if err := proto.Unmarshal(payload.Header.ChannelHeader, chdr); err != nil {
+		return nil, err
+	}
+	if common.HeaderType(chdr.Type) != common.HeaderType_ENDORSER_TRANSACTION {
+		return nil, nil
+	}
+
+	tx := &pb.Transaction{}
+	if err := proto.Unmarshal(payload.Data, tx); err != nil {
+		return nil, err
+	}
+	rec := &txRecord{TxID: chdr.TxId}
+	if chdr.Timestamp != nil {
+		rec.Timestamp = time.Unix(chdr.Timestamp.Seconds, int64(chdr.Timestamp.Nanos)).UTC()
+	}
+	for _, action := range tx.Actions {
+		actionPayload := &pb.ChaincodeActionPayload{}
+		if err := proto.Unmarshal(action.Payload, actionPayload); err != nil {
+			return nil, err
+		}
+		if actionPayload.Action == nil {
+			continue
+		}
+		prp := &pb.ProposalResponsePayload{}
+		if err := proto.Unmarshal(actionPayload.Action.ProposalResponsePayload, prp); err != nil {
+			return nil, err
+		}
+		ca := &pb.ChaincodeAction{}
+		if err := proto.Unmarshal(prp.Extension, ca); err != nil {
+			return nil, err
+		}
+		ev := &pb.ChaincodeEvent{}
+		if err := proto.Unmarshal(ca.Events, ev); err != nil {
+			return nil, err
+		}
+		if ev.EventName != eventName {
+			continue
+		}
+		events := []chaincodeEvent{}
+		if err := json.Unmarshal(ev.Payload, &events); err != nil {
+			return nil, fmt.Errorf("invalid payload of event %s: %s", ev.EventName, err)
+		}
+		rec.Events = append(rec.Events, events...)
+	}
+	if len(rec.Events) == 0 {
+		return nil, nil
+	}
+	return rec, nil
+}
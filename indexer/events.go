@@ -0,0 +1,82 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// The indexer does not share code with the chaincode, it decodes the
+// parts of the chaincode's events it needs.
+
+// txRecord holds the events of a transaction, as a line of an events
+// file or taken from a block.
+type txRecord struct {
+	TxID      string           `json:"txId"`
+	Timestamp time.Time        `json:"timestamp"`
+	Events    []chaincodeEvent `json:"events"`
+}
+
+// chaincodeEvent is a single business event. The chaincode sends all
+// events of a transaction as a JSON array in one chaincode event.
+type chaincodeEvent struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload"`
+}
+
+// Event types the indexer processes, others are skipped
+const (
+	participantSavedType = "ParticipantSaved"
+	shipmentSavedType    = "ShipmentSaved"
+	trackingDataType     = "TrackingData"
+)
+
+type participantSavedEvent struct {
+	Kind        string          `json:"kind"`
+	Participant json.RawMessage `json:"participant"`
+}
+
+type address struct {
+	PostalCode string `json:"postalCode"`
+	City       string `json:"city"`
+	Country    string `json:"country"`
+}
+
+type participant struct {
+	ID      string  `json:"id"`
+	Name    string  `json:"name"`
+	Status  string  `json:"status"`
+	Address address `json:"address"`
+}
+
+type shipmentSavedEvent struct {
+	Shipment json.RawMessage `json:"shipment"`
+}
+
+type shipment struct {
+	ID          string    `json:"id"`
+	ShipperID   string    `json:"by"`
+	FromID      string    `json:"from"`
+	ToID        string    `json:"to"`
+	Status      string    `json:"status"`
+	ParentID    string    `json:"parent"`
+	ContainerID string    `json:"container"`
+	SubmittedAt time.Time `json:"submittime"`
+	DelivererAt time.Time `json:"delivertime"`
+}
+
+type trackingDataEvent struct {
+	ShipmentID string          `json:"shipmentId"`
+	Points     []trackingPoint `json:"points"`
+}
+
+type trackingPoint struct {
+	DeviceID    string    `json:"device"`
+	Counter     uint64    `json:"counter"`
+	At          time.Time `json:"at"`
+	Latitude    float64   `json:"lat"`
+	Longitude   float64   `json:"lng"`
+	Temperature float32   `json:"temp"`
+	Humidity    float32   `json:"hum"`
+}
@@ -0,0 +1,120 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// indexEventsFile processes an events file of JSON lines, one txRecord
+// per line. The checkpoint is the offset after the last line processed.
+// Stdin has no checkpoint, transactions are still processed once only.
+func indexEventsFile(st *store, path string, follow bool, poll time.Duration) error {
+	if path == "-" {
+		_, err := indexEvents(st, "stdin", 0, bufio.NewReader(os.Stdin), true)
+		return err
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	source := "events:" + abs
+	offset, _, err := st.checkpoint(source)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if fi.Size() < offset {
+		logger.Printf("%s is shorter than its checkpoint, starting over", path)
+		offset = 0
+	}
+	if _, err = f.Seek(offset, io.SeekStart); err != nil {
+		return err
+	}
+	logger.Printf("reading %s from offset %d", path, offset)
+
+	r := bufio.NewReader(f)
+	for {
+		offset, err = indexEvents(st, source, offset, r, !follow)
+		if err == errIncompleteLine {
+			// read the line again when it is complete
+			if _, err = f.Seek(offset, io.SeekStart); err != nil {
+				return err
+			}
+			r.Reset(f)
+		} else if err != nil || !follow {
+			return err
+		}
+		time.Sleep(poll)
+	}
+}
+
+// errIncompleteLine is returned at the end of input not ending in
+// a newline, unless it is the final input. The line may still be
+// written.
+var errIncompleteLine = errors.New("incomplete line")
+
+// indexEvents processes lines until the end of r, starting at offset.
+// If r is final, a last line without newline is processed, too. Returns
+// the offset after the last line processed.
+func indexEvents(st *store, source string, offset int64, r *bufio.Reader, final bool) (int64, error) {
+	applied, skipped := 0, 0
+	defer func() {
+		if applied+skipped > 0 {
+			logger.Printf("processed %d transactions, skipped %d seen before", applied, skipped)
+		}
+	}()
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return offset, err
+		}
+		eof := err == io.EOF
+		if eof && len(bytes.TrimSpace(line)) > 0 && !final {
+			return offset, errIncompleteLine
+		}
+		offset += int64(len(line))
+		if len(bytes.TrimSpace(line)) == 0 {
+			if eof {
+				return offset, nil
+			}
+			continue
+		}
+
+		var rec txRecord
+		if err = json.Unmarshal(line, &rec); err != nil {
+			return offset, fmt.Errorf("invalid line ending at offset %d: %s", offset, err)
+		}
+		ok, err := st.apply(source, offset, rec, source != "stdin")
+		if err != nil {
+			return offset, err
+		}
+		if ok {
+			applied++
+		} else {
+			skipped++
+		}
+		if eof {
+			return offset, nil
+		}
+	}
+}
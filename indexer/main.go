@@ -0,0 +1,74 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+)
+
+var logger = log.New(os.Stderr, "pcs-indexer: ", log.Ldate|log.Ltime|log.Lmicroseconds)
+
+const usage = `usage: pcs-indexer [flags] [block files]
+
+Maintains a SQLite database of participants, shipments, status history
+and tracking points from the events of the precious cargo chaincode.
+
+Events are read from an events file of JSON lines, as written by the
+memory backend of pcs (-events), or from block files as fetched by
+'peer channel fetch'. Transactions are processed once, by ID, and the
+position in the input is kept, so the indexer resumes where it stopped.
+
+flags:
+`
+
+func main() {
+	flags := flag.NewFlagSet("pcs-indexer", flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flags.PrintDefaults()
+	}
+	dbFile := flags.String("db", "pcs-index.db", "SQLite database file")
+	eventsFile := flags.String("events", "", "events file of JSON lines, - for stdin")
+	follow := flags.Bool("follow", false, "keep reading the events file as it grows")
+	poll := flags.Duration("poll", time.Second, "interval to check a followed events file for new lines")
+	eventName := flags.String("event-name", "sample.PreciousCargoChaincode", "name of the chaincode event, in block files")
+	synthetic := flags.Int("synthetic", 0, "write a synthetic events file of this many shipments to stdout, and exit")
+	flags.Parse(os.Args[1:])
+
+	var err error
+	switch {
+	case *synthetic > 0:
+		err = writeSyntheticEvents(os.Stdout, *synthetic, 1)
+	case *eventsFile != "" && flags.NArg() > 0:
+		err = fmt.Errorf("either -events or block files, not both")
+	case *eventsFile != "":
+		err = withStore(*dbFile, func(st *store) error {
+			return indexEventsFile(st, *eventsFile, *follow, *poll)
+		})
+	case flags.NArg() > 0:
+		err = withStore(*dbFile, func(st *store) error {
+			return indexBlockFiles(st, flags.Args(), *eventName)
+		})
+	default:
+		flags.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "pcs-indexer: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// withStore opens the database, runs fn and closes it again
+func withStore(path string, fn func(*store) error) error {
+	st, err := openStore(path)
+	if err != nil {
+		return err
+	}
+	defer st.close()
+	return fn(st)
+}
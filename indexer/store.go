@@ -0,0 +1,289 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3" // registers the sqlite3 driver
+)
+
+// Times are stored as UTC text of fixed length, so that they sort
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+var schema = []string{
+	`CREATE TABLE IF NOT EXISTS participants (
+		id          TEXT PRIMARY KEY,
+		kind        TEXT NOT NULL,
+		name        TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		postal_code TEXT,
+		city        TEXT,
+		country     TEXT,
+		data        TEXT NOT NULL,
+		tx_id       TEXT NOT NULL,
+		updated_at  TEXT NOT NULL
+	)`,
+	`CREATE TABLE IF NOT EXISTS shipments (
+		id           TEXT PRIMARY KEY,
+		shipper      TEXT NOT NULL,
+		sender       TEXT NOT NULL,
+		recipient    TEXT NOT NULL,
+		status       TEXT NOT NULL,
+		parent       TEXT,
+		container    TEXT,
+		submitted_at TEXT,
+		delivered_at TEXT,
+		data         TEXT NOT NULL,
+		tx_id        TEXT NOT NULL,
+		updated_at   TEXT NOT NULL
+	)`,
+	`CREATE INDEX IF NOT EXISTS shipments_by_status ON shipments (status)`,
+	`CREATE INDEX IF NOT EXISTS shipments_by_shipper ON shipments (shipper)`,
+	`CREATE INDEX IF NOT EXISTS shipments_by_sender ON shipments (sender)`,
+	`CREATE INDEX IF NOT EXISTS shipments_by_recipient ON shipments (recipient)`,
+	`CREATE TABLE IF NOT EXISTS shipment_status (
+		shipment_id TEXT NOT NULL,
+		status      TEXT NOT NULL,
+		at          TEXT NOT NULL,
+		tx_id       TEXT NOT NULL,
+		PRIMARY KEY (shipment_id, tx_id, status)
+	)`,
+	`CREATE INDEX IF NOT EXISTS shipment_status_by_at ON shipment_status (shipment_id, at)`,
+	`CREATE TABLE IF NOT EXISTS tracking_points (
+		shipment_id TEXT NOT NULL,
+		device      TEXT NOT NULL,
+		counter     INTEGER NOT NULL,
+		at          TEXT NOT NULL,
+		lat         REAL NOT NULL,
+		lng         REAL NOT NULL,
+		temp        REAL NOT NULL,
+		hum         REAL NOT NULL,
+		tx_id       TEXT NOT NULL,
+		PRIMARY KEY (shipment_id, device, at)
+	)`,
+	`CREATE INDEX IF NOT EXISTS tracking_points_by_at ON tracking_points (shipment_id, at)`,
+	// transactions processed, for idempotency
+	`CREATE TABLE IF NOT EXISTS processed_tx (
+		tx_id        TEXT PRIMARY KEY,
+		source       TEXT NOT NULL,
+		position     INTEGER NOT NULL,
+		timestamp    TEXT NOT NULL,
+		processed_at TEXT NOT NULL
+	)`,
+	// positions within the inputs, to resume
+	`CREATE TABLE IF NOT EXISTS checkpoints (
+		source     TEXT PRIMARY KEY,
+		position   INTEGER NOT NULL,
+		updated_at TEXT NOT NULL
+	)`,
+}
+
+// store is the SQLite database maintained by the indexer
+type store struct {
+	db *sql.DB
+}
+
+func openStore(path string) (*store, error) {
+	db, err := sql.Open("sqlite3", path+"?_busy_timeout=5000&_journal_mode=WAL")
+	if err != nil {
+		return nil, err
+	}
+	// a single writer, SQLite serializes writes anyway
+	db.SetMaxOpenConns(1)
+	for _, stmt := range schema {
+		if _, err = db.Exec(stmt); err != nil {
+			db.Close()
+			return nil, fmt.Errorf("unable to create schema: %s", err)
+		}
+	}
+	return &store{db: db}, nil
+}
+
+func (st *store) close() error {
+	return st.db.Close()
+}
+
+// checkpoint returns the position stored for a source, if any
+func (st *store) checkpoint(source string) (int64, bool, error) {
+	var pos int64
+	err := st.db.QueryRow(`SELECT position FROM checkpoints WHERE source = ?`, source).Scan(&pos)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	return pos, true, nil
+}
+
+// setCheckpoint stores the position for a source
+func (st *store) setCheckpoint(source string, position int64) error {
+	return setCheckpoint(st.db, source, position)
+}
+
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func setCheckpoint(db execer, source string, position int64) error {
+	_, err := db.Exec(`INSERT INTO checkpoints (source, position, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT (source) DO UPDATE SET position = excluded.position, updated_at = excluded.updated_at`,
+		source, position, formatTime(time.Now()))
+	return err
+}
+
+// apply processes the events of a transaction found at a position of
+// a source, unless it has been processed before. If checkpoint is set,
+// the checkpoint of the source is moved to the position in the same
+// database transaction. Returns whether the transaction has been
+// processed now.
+func (st *store) apply(source string, position int64, rec txRecord, checkpoint bool) (bool, error) {
+	if rec.TxID == "" {
+		return false, fmt.Errorf("transaction without ID at position %d", position)
+	}
+	tx, err := st.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	var n int
+	err = tx.QueryRow(`SELECT COUNT(*) FROM processed_tx WHERE tx_id = ?`, rec.TxID).Scan(&n)
+	if err != nil {
+		return false, err
+	}
+	applied := n == 0
+	if applied {
+		for i, ev := range rec.Events {
+			if err = applyEvent(tx, rec, ev); err != nil {
+				return false, fmt.Errorf("transaction %s, event %d (%s): %s", rec.TxID, i, ev.Type, err)
+			}
+		}
+		_, err = tx.Exec(`INSERT INTO processed_tx (tx_id, source, position, timestamp, processed_at) VALUES (?, ?, ?, ?, ?)`,
+			rec.TxID, source, position, formatTime(rec.Timestamp), formatTime(time.Now()))
+		if err != nil {
+			return false, err
+		}
+	}
+	if checkpoint {
+		if err = setCheckpoint(tx, source, position); err != nil {
+			return false, err
+		}
+	}
+	return applied, tx.Commit()
+}
+
+func applyEvent(tx *sql.Tx, rec txRecord, ev chaincodeEvent) error {
+	switch ev.Type {
+	case participantSavedType:
+		var e participantSavedEvent
+		if err := json.Unmarshal(ev.Payload, &e); err != nil {
+			return err
+		}
+		return applyParticipant(tx, rec, e)
+	case shipmentSavedType:
+		var e shipmentSavedEvent
+		if err := json.Unmarshal(ev.Payload, &e); err != nil {
+			return err
+		}
+		return applyShipment(tx, rec, e)
+	case trackingDataType:
+		var e trackingDataEvent
+		if err := json.Unmarshal(ev.Payload, &e); err != nil {
+			return err
+		}
+		return applyTrackingData(tx, rec, e)
+	}
+	return nil
+}
+
+func applyParticipant(tx *sql.Tx, rec txRecord, e participantSavedEvent) error {
+	var p participant
+	if err := json.Unmarshal(e.Participant, &p); err != nil {
+		return err
+	}
+	if p.ID == "" {
+		return fmt.Errorf("participant without ID")
+	}
+	// participants registered before there was a status are active
+	status := p.Status
+	if status == "" {
+		status = "active"
+	}
+	_, err := tx.Exec(`INSERT OR REPLACE INTO participants
+		(id, kind, name, status, postal_code, city, country, data, tx_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		p.ID, e.Kind, p.Name, status, p.Address.PostalCode, p.Address.City, p.Address.Country,
+		string(e.Participant), rec.TxID, formatTime(rec.Timestamp))
+	return err
+}
+
+// applyShipment stores the shipment and adds to its status history if
+// its status changed.
+func applyShipment(tx *sql.Tx, rec txRecord, e shipmentSavedEvent) error {
+	var s shipment
+	if err := json.Unmarshal(e.Shipment, &s); err != nil {
+		return err
+	}
+	if s.ID == "" {
+		return fmt.Errorf("shipment without ID")
+	}
+
+	var status string
+	err := tx.QueryRow(`SELECT status FROM shipments WHERE id = ?`, s.ID).Scan(&status)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+	if err == sql.ErrNoRows || status != s.Status {
+		_, err = tx.Exec(`INSERT OR IGNORE INTO shipment_status (shipment_id, status, at, tx_id) VALUES (?, ?, ?, ?)`,
+			s.ID, s.Status, formatTime(rec.Timestamp), rec.TxID)
+		if err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.Exec(`INSERT OR REPLACE INTO shipments
+		(id, shipper, sender, recipient, status, parent, container, submitted_at, delivered_at, data, tx_id, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ID, s.ShipperID, s.FromID, s.ToID, s.Status, nullString(s.ParentID), nullString(s.ContainerID),
+		nullTime(s.SubmittedAt), nullTime(s.DelivererAt), string(e.Shipment), rec.TxID, formatTime(rec.Timestamp))
+	return err
+}
+
+// applyTrackingData stores tracking points. A device measures once at
+// a time, so points seen before are skipped.
+func applyTrackingData(tx *sql.Tx, rec txRecord, e trackingDataEvent) error {
+	for _, p := range e.Points {
+		_, err := tx.Exec(`INSERT OR IGNORE INTO tracking_points
+			(shipment_id, device, counter, at, lat, lng, temp, hum, tx_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.ShipmentID, p.DeviceID, int64(p.Counter), formatTime(p.At),
+			p.Latitude, p.Longitude, p.Temperature, p.Humidity, rec.TxID)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(timeFormat)
+}
+
+func nullTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return formatTime(t)
+}
+
+func nullString(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
@@ -0,0 +1,402 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/protobuf/ptypes/timestamp"
+	"github.com/hyperledger/fabric/protos/common"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+const (
+	testShipments = 20
+	testEventName = "sample.PreciousCargoChaincode"
+)
+
+// rows the synthetic stream of testShipments leaves in the tables
+var wantRows = map[string]int{
+	"participants":    3,
+	"shipments":       testShipments,
+	"shipment_status": 3 * testShipments,
+	"tracking_points": syntheticPointsPerShipment * testShipments,
+}
+
+func TestReplayIsNoOp(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := openTestStore(t, dir)
+	defer st.close()
+
+	txs := newSyntheticStream(testShipments, 1).txs
+	for i, rec := range txs {
+		applied, err := st.apply("test", int64(i), rec, false)
+		if err != nil {
+			t.Fatalf("transaction %d: %s", i, err)
+		}
+		if !applied {
+			t.Fatalf("transaction %s not applied the first time", rec.TxID)
+		}
+	}
+	checkRows(t, st, "first pass", len(txs))
+	before := dumpTables(t, st)
+
+	// replayed in full, and its first half once more
+	replay := append(append([]txRecord{}, txs...), txs[:len(txs)/2]...)
+	for i, rec := range replay {
+		applied, err := st.apply("test", int64(len(txs)+i), rec, false)
+		if err != nil {
+			t.Fatalf("replayed transaction %d: %s", i, err)
+		}
+		if applied {
+			t.Fatalf("replayed transaction %s applied again", rec.TxID)
+		}
+	}
+	checkRows(t, st, "replay", len(txs))
+	if after := dumpTables(t, st); after != before {
+		t.Fatalf("replay changed the tables:\nbefore:\n%s\nafter:\n%s", before, after)
+	}
+}
+
+func TestEventsFileResumesFromCheckpoint(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	dbFile := filepath.Join(dir, "index.db")
+	eventsFile := filepath.Join(dir, "events.jsonl")
+
+	txs := newSyntheticStream(testShipments, 1).txs
+	half := len(txs) / 2
+	st := openTestStore(t, dir)
+	appendEvents(t, eventsFile, txs[:half])
+	if err := indexEventsFile(st, eventsFile, false, 0); err != nil {
+		t.Fatalf("first half: %s", err)
+	}
+	if err := st.close(); err != nil {
+		t.Fatalf("unable to close store: %s", err)
+	}
+
+	// the lines read before are no longer valid, reading them again fails
+	fi, err := os.Stat(eventsFile)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	garbleLines(t, eventsFile)
+	appendEvents(t, eventsFile, txs[half:])
+
+	st, err = openStore(dbFile)
+	if err != nil {
+		t.Fatalf("unable to reopen store: %s", err)
+	}
+	defer st.close()
+	abs, err := filepath.Abs(eventsFile)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	offset, found, err := st.checkpoint("events:" + abs)
+	if err != nil {
+		t.Fatalf("unable to read checkpoint: %s", err)
+	}
+	if !found || offset != fi.Size() {
+		t.Fatalf("checkpoint after first half is %d (found %v), want %d", offset, found, fi.Size())
+	}
+	if err = indexEventsFile(st, eventsFile, false, 0); err != nil {
+		t.Fatalf("second half, resuming: %s", err)
+	}
+	checkRows(t, st, "second half", len(txs))
+}
+
+func TestBlockFilesResumeFromCheckpoint(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	dbFile := filepath.Join(dir, "index.db")
+
+	txs := newSyntheticStream(testShipments, 1).txs
+	files, err := writeSyntheticBlocks(dir, txs, 10, testEventName)
+	if err != nil {
+		t.Fatalf("unable to write blocks: %s", err)
+	}
+	half := len(files) / 2
+
+	st := openTestStore(t, dir)
+	if err = indexBlockFiles(st, files[:half], testEventName); err != nil {
+		t.Fatalf("first blocks: %s", err)
+	}
+	if err = st.close(); err != nil {
+		t.Fatalf("unable to close store: %s", err)
+	}
+
+	st, err = openStore(dbFile)
+	if err != nil {
+		t.Fatalf("unable to reopen store: %s", err)
+	}
+	defer st.close()
+	last, found, err := st.checkpoint(blockSource)
+	if err != nil {
+		t.Fatalf("unable to read checkpoint: %s", err)
+	}
+	if !found || last != int64(half) {
+		t.Fatalf("checkpoint after first blocks is %d (found %v), want %d", last, found, half)
+	}
+
+	// all blocks again, the first ones are skipped by the checkpoint
+	if err = indexBlockFiles(st, files, testEventName); err != nil {
+		t.Fatalf("all blocks, resuming: %s", err)
+	}
+	checkRows(t, st, "all blocks", len(txs))
+	var n int
+	err = st.db.QueryRow(`SELECT COUNT(*) FROM processed_tx WHERE position <= ?`, half).Scan(&n)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if want := half * 10; n != want {
+		t.Fatalf("%d transactions from the first blocks, want %d", n, want)
+	}
+}
+
+func TestStatusHistory(t *testing.T) {
+	dir := tempDir(t)
+	defer os.RemoveAll(dir)
+	st := openTestStore(t, dir)
+	defer st.close()
+
+	txs := newSyntheticStream(testShipments, 1).txs
+	for i, rec := range txs {
+		if _, err := st.apply("test", int64(i), rec, false); err != nil {
+			t.Fatalf("transaction %d: %s", i, err)
+		}
+	}
+
+	rows, err := st.db.Query(`SELECT shipment_id, group_concat(status, ',') FROM
+		(SELECT shipment_id, status FROM shipment_status ORDER BY shipment_id, at)
+		GROUP BY shipment_id`)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer rows.Close()
+	n := 0
+	for rows.Next() {
+		var id, history string
+		if err = rows.Scan(&id, &history); err != nil {
+			t.Fatalf("%s", err)
+		}
+		if history != "submitted,in-transit,delivered" {
+			t.Fatalf("status history of shipment %s is %s", id, history)
+		}
+		n++
+	}
+	if err = rows.Err(); err != nil {
+		t.Fatalf("%s", err)
+	}
+	if n != testShipments {
+		t.Fatalf("status history of %d shipments, want %d", n, testShipments)
+	}
+
+	var status string
+	err = st.db.QueryRow(`SELECT status FROM shipments WHERE id = '100'`).Scan(&status)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if status != "delivered" {
+		t.Fatalf("shipment 100 is %s, want delivered", status)
+	}
+}
+
+func tempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "pcs-indexer")
+	if err != nil {
+		t.Fatalf("unable to create temporary directory: %s", err)
+	}
+	return dir
+}
+
+func openTestStore(t *testing.T, dir string) *store {
+	st, err := openStore(filepath.Join(dir, "index.db"))
+	if err != nil {
+		t.Fatalf("unable to open store: %s", err)
+	}
+	return st
+}
+
+// checkRows compares the number of rows in each table
+func checkRows(t *testing.T, st *store, step string, processed int) {
+	want := map[string]int{"processed_tx": processed}
+	for table, n := range wantRows {
+		want[table] = n
+	}
+	for table, n := range want {
+		var got int
+		if err := st.db.QueryRow(`SELECT COUNT(*) FROM ` + table).Scan(&got); err != nil {
+			t.Fatalf("%s: %s", step, err)
+		}
+		if got != n {
+			t.Fatalf("%s: %d rows in %s, want %d", step, got, table, n)
+		}
+	}
+}
+
+// dumpTables returns the rows of all tables which hold indexed data
+func dumpTables(t *testing.T, st *store) string {
+	var b strings.Builder
+	for _, q := range []string{
+		`SELECT id, kind, name, status, data, tx_id, updated_at FROM participants ORDER BY id`,
+		`SELECT id, status, data, tx_id, updated_at FROM shipments ORDER BY id`,
+		`SELECT shipment_id, status, at, tx_id FROM shipment_status ORDER BY shipment_id, at`,
+		`SELECT shipment_id, device, counter, at, tx_id FROM tracking_points ORDER BY shipment_id, device, at`,
+	} {
+		rows, err := st.db.Query(q)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		cols, err := rows.Columns()
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+		for rows.Next() {
+			values := make([]interface{}, len(cols))
+			for i := range values {
+				values[i] = new(string)
+			}
+			if err = rows.Scan(values...); err != nil {
+				t.Fatalf("%s", err)
+			}
+			for _, v := range values {
+				fmt.Fprintf(&b, "%s|", *v.(*string))
+			}
+			b.WriteByte('\n')
+		}
+		rows.Close()
+	}
+	return b.String()
+}
+
+func appendEvents(t *testing.T, path string, txs []txRecord) {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	if err = writeEvents(f, txs); err != nil {
+		f.Close()
+		t.Fatalf("%s", err)
+	}
+	if err = f.Close(); err != nil {
+		t.Fatalf("%s", err)
+	}
+}
+
+// garbleLines replaces all lines of a file with invalid ones of the same
+// length
+func garbleLines(t *testing.T, path string) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	for i, c := range data {
+		if c != '\n' {
+			data[i] = 'x'
+		}
+	}
+	if err = ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatalf("%s", err)
+	}
+}
+
+// syntheticBlock wraps transactions into a block the way a peer does,
+// followed by an invalid transaction which must be skipped.
+func syntheticBlock(number uint64, txs []txRecord, eventName string) ([]byte, error) {
+	b := &common.Block{
+		Header:   &common.BlockHeader{Number: number},
+		Data:     &common.BlockData{},
+		Metadata: &common.BlockMetadata{Metadata: make([][]byte, len(common.BlockMetadataIndex_name))},
+	}
+	filter := []byte{}
+	invalid := txRecord{
+		TxID:      fmt.Sprintf("invalid%08d", number),
+		Timestamp: time.Now(),
+		Events:    []chaincodeEvent{event(shipmentSavedType, map[string]interface{}{"shipment": map[string]string{"id": "invalid"}})},
+	}
+	for _, rec := range append(append([]txRecord{}, txs...), invalid) {
+		env, err := syntheticEnvelope(rec, eventName)
+		if err != nil {
+			return nil, err
+		}
+		b.Data.Data = append(b.Data.Data, env)
+		filter = append(filter, byte(pb.TxValidationCode_VALID))
+	}
+	filter[len(filter)-1] = byte(pb.TxValidationCode_MVCC_READ_CONFLICT)
+	b.Metadata.Metadata[common.BlockMetadataIndex_TRANSACTIONS_FILTER] = filter
+	return proto.Marshal(b)
+}
+
+func syntheticEnvelope(rec txRecord, eventName string) ([]byte, error) {
+	payload, err := json.Marshal(rec.Events)
+	if err != nil {
+		return nil, err
+	}
+	ev, err := proto.Marshal(&pb.ChaincodeEvent{ChaincodeId: "pcs", TxId: rec.TxID, EventName: eventName, Payload: payload})
+	if err != nil {
+		return nil, err
+	}
+	ca, err := proto.Marshal(&pb.ChaincodeAction{Events: ev})
+	if err != nil {
+		return nil, err
+	}
+	prp, err := proto.Marshal(&pb.ProposalResponsePayload{Extension: ca})
+	if err != nil {
+		return nil, err
+	}
+	actionPayload, err := proto.Marshal(&pb.ChaincodeActionPayload{
+		Action: &pb.ChaincodeEndorsedAction{ProposalResponsePayload: prp},
+	})
+	if err != nil {
+		return nil, err
+	}
+	tx, err := proto.Marshal(&pb.Transaction{Actions: []*pb.TransactionAction{{Payload: actionPayload}}})
+	if err != nil {
+		return nil, err
+	}
+	chdr, err := proto.Marshal(&common.ChannelHeader{
+		Type:      int32(common.HeaderType_ENDORSER_TRANSACTION),
+		TxId:      rec.TxID,
+		Timestamp: &timestamp.Timestamp{Seconds: rec.Timestamp.Unix(), Nanos: int32(rec.Timestamp.Nanosecond())},
+	})
+	if err != nil {
+		return nil, err
+	}
+	p, err := proto.Marshal(&common.Payload{Header: &common.Header{ChannelHeader: chdr}, Data: tx})
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(&common.Envelope{Payload: p})
+}
+
+// writeSyntheticBlocks writes block files of up to perBlock transactions
+// each, numbered from 1. Returns the file names.
+func writeSyntheticBlocks(dir string, txs []txRecord, perBlock int, eventName string) ([]string, error) {
+	files := []string{}
+	for i := 0; i < len(txs); i += perBlock {
+		end := i + perBlock
+		if end > len(txs) {
+			end = len(txs)
+		}
+		number := uint64(len(files) + 1)
+		data, err := syntheticBlock(number, txs[i:end], eventName)
+		if err != nil {
+			return nil, err
+		}
+		file := filepath.Join(dir, fmt.Sprintf("block%d.pb", number))
+		if err = ioutil.WriteFile(file, data, 0600); err != nil {
+			return nil, err
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
@@ -0,0 +1,131 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"time"
+)
+
+// Synthetic event streams look like those of the chaincode: a carrier,
+// a sender and a recipient register, then each shipment is submitted,
+// departs, reports tracking data and arrives.
+
+const syntheticPointsPerShipment = 5
+
+// syntheticStream generates transactions of n shipments
+type syntheticStream struct {
+	rnd  *rand.Rand
+	at   time.Time
+	txNo int
+	txs  []txRecord
+}
+
+func newSyntheticStream(n int, seed int64) *syntheticStream {
+	g := &syntheticStream{
+		rnd: rand.New(rand.NewSource(seed)),
+		at:  time.Date(2019, 6, 1, 8, 0, 0, 0, time.UTC),
+	}
+	g.participant("ShipmentCo", "1", "Precious Cargo Carriers", "20095", "Hamburg", "DE")
+	g.participant("CorporateParticipant", "2", "Sender Ltd", "EC1A 1BB", "London", "GB")
+	g.participant("IndividualParticipant", "3", "Jane Recipient", "10115", "Berlin", "DE")
+	for i := 0; i < n; i++ {
+		g.shipment(fmt.Sprintf("%d", 100+i))
+	}
+	return g
+}
+
+func (g *syntheticStream) tx(events ...chaincodeEvent) {
+	g.txNo++
+	g.at = g.at.Add(time.Duration(1+g.rnd.Intn(60)) * time.Second)
+	g.txs = append(g.txs, txRecord{
+		TxID:      fmt.Sprintf("synthetic%08d", g.txNo),
+		Timestamp: g.at,
+		Events:    events,
+	})
+}
+
+func event(typ string, payload interface{}) chaincodeEvent {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		panic(err)
+	}
+	return chaincodeEvent{Type: typ, Payload: data}
+}
+
+func (g *syntheticStream) participant(kind, id, name, postalCode, city, country string) {
+	p := map[string]interface{}{
+		"id":   id,
+		"name": name,
+		"address": map[string]string{
+			"postalCode": postalCode,
+			"city":       city,
+			"country":    country,
+		},
+	}
+	g.tx(event(participantSavedType, map[string]interface{}{"kind": kind, "participant": p}))
+}
+
+func (g *syntheticStream) shipment(id string) {
+	s := map[string]interface{}{
+		"id":         id,
+		"docType":    "Shipment",
+		"by":         "1",
+		"from":       "2",
+		"to":         "3",
+		"status":     "submitted",
+		"submittime": g.at,
+	}
+	save := func() chaincodeEvent {
+		return event(shipmentSavedType, map[string]interface{}{"shipment": s})
+	}
+	g.tx(save())
+
+	s["status"] = "in-transit"
+	g.tx(save())
+
+	lat, lng := 53.55, 9.99
+	for i := 0; i < syntheticPointsPerShipment; i++ {
+		lat, lng = lat-0.3*g.rnd.Float64(), lng+0.1*g.rnd.Float64()
+		point := map[string]interface{}{
+			"shipmentId": map[string]string{"id": id},
+			"device":     "tracker-" + id,
+			"counter":    i + 1,
+			"at":         g.at.Add(time.Duration(i) * time.Minute),
+			"lat":        lat,
+			"lng":        lng,
+			"temp":       4 + 2*g.rnd.Float64(),
+			"hum":        40 + 10*g.rnd.Float64(),
+		}
+		g.tx(event(trackingDataType, map[string]interface{}{
+			"shipmentId": id,
+			"points":     []interface{}{point},
+		}), event("GeofenceEvent", map[string]string{"shipmentId": id, "type": "enter"}))
+	}
+
+	s["status"] = "delivered"
+	s["delivertime"] = g.at
+	g.tx(save())
+}
+
+// writeSyntheticEvents writes an events file of n shipments
+func writeSyntheticEvents(w io.Writer, n int, seed int64) error {
+	return writeEvents(w, newSyntheticStream(n, seed).txs)
+}
+
+func writeEvents(w io.Writer, txs []txRecord) error {
+	bw := bufio.NewWriter(w)
+	for _, rec := range txs {
+		line, err := json.Marshal(rec)
+		if err != nil {
+			return err
+		}
+		bw.Write(line)
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
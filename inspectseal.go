@@ -0,0 +1,143 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	inspectSealSchema = `
+{
+	"$id": "PreciousCargoShippping:inspectSealSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Container"
+		},
+		"seal": {
+			"type": "string",
+			"description": "number of the seal inspected"
+		},
+		"intact": {
+			"type": "boolean",
+			"description": "false if the seal shows signs of tampering"
+		},
+		"remarks": {
+			"type": "string",
+			"maxLength": 500
+		}
+	},
+	"required": [ "id", "seal", "intact" ]
+}
+`
+	inspectSealSchemaLoader = gojsonschema.NewStringLoader(inspectSealSchema)
+)
+
+// Records the inspection of a seal. A seal found not intact is considered
+// broken, and all shipments in the container are flagged.
+type inspectSealArg struct {
+	ID      string `json:"id"`
+	Seal    string `json:"seal"`
+	Intact  bool   `json:"intact"`
+	Remarks string `json:"remarks"`
+}
+
+type inspectSealInvocation struct {
+	eventRecorder
+
+	arg inspectSealArg
+
+	container *Container
+	seal      *Seal
+
+	res SealCheck
+}
+
+func (inv *inspectSealInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter inspectSealInvocation.checkParseArguments")
+
+	inv.arg = inspectSealArg{}
+	err := parseArgument(stub, inspectSealSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.container, err = getContainer(stub, inv.arg.ID)
+	if err != nil {
+		return err
+	}
+	if err = checkCallerHandlesContainer(stub, inv.container); err != nil {
+		return err
+	}
+	inv.seal, err = inv.container.seal(inv.arg.Seal)
+	if err != nil {
+		return fmt.Errorf("invalid seal argument: %s", err)
+	}
+	if inv.seal.Status != SealIntact {
+		return errors.New("invalid seal argument: Seal is broken already")
+	}
+	return nil
+}
+
+func (inv *inspectSealInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter inspectSealInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	by, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+
+	inv.res = SealCheck{
+		ContainerID: inv.container.ID.ID,
+		SealNumber:  inv.seal.Number,
+		Action:      SealActionInspected,
+		Intact:      inv.arg.Intact,
+		By:          by,
+		At:          now,
+		Remarks:     inv.arg.Remarks,
+	}
+	if err = putSealCheck(stub, inv.res); err != nil {
+		return err
+	}
+	inv.emit(sealCheckType, inv.res)
+
+	if inv.arg.Intact {
+		return nil
+	}
+
+	inv.seal.Status = SealBroken
+	inv.seal.BrokenAt = &now
+	if err = containerRegistry().update(stub, inv.container.ID.ID, inv.container); err != nil {
+		return err
+	}
+
+	flag := ShipmentFlag{
+		Type:    FlagSealTampered,
+		At:      now,
+		Details: fmt.Sprintf("seal %s of container %s found not intact", inv.seal.Number, inv.container.ID.ID),
+	}
+	flagged, err := flagShipments(stub, &inv.eventRecorder, inv.container, flag)
+	if err != nil {
+		return err
+	}
+	for _, id := range flagged {
+		inv.emit("ShipmentFlagged", shipmentFlagEvent{ShipmentID: id, Flag: flag})
+	}
+
+	return nil
+}
+
+func (inv *inspectSealInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
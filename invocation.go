@@ -0,0 +1,153 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	pb "github.com/hyperledger/fabric/protos/peer"
+)
+
+// Responses of successful invocations tell in their message whether the
+// function is a transaction or a query, so that gateways not knowing it can
+// evaluate it first and submit it only if it is a transaction.
+const (
+	responseTransaction = "transaction"
+	responseQuery       = "query"
+)
+
+var errReadOnly = errors.New("internal error: function does not change state")
+
+// invocationStub is the stub Invoke hands to handlers. Fabric runs
+// invocations concurrently, so each one logs through its own logger,
+// which adds transaction, channel and function to each line, and logs
+// at the level of the configuration.
+//
+// Queries get a read-only stub, which refuses writes even if a query is
+// submitted as a transaction.
+type invocationStub struct {
+	shim.ChaincodeStubInterface
+	base     *leveledLogger
+	logger   *leveledLogger // configured, once used
+	readOnly bool
+}
+
+func newInvocationStub(stub shim.ChaincodeStubInterface, function string, readOnly bool) *invocationStub {
+	return &invocationStub{
+		ChaincodeStubInterface: stub,
+		base:                   logger.with("tx", stub.GetTxID(), "channel", stub.GetChannelID(), "function", function),
+		readOnly:               readOnly,
+	}
+}
+
+// log returns the configured logger of the invocation. The configuration
+// is read from the world state the first time the invocation logs, unless
+// the environment fixes level and format, and kept for the rest of the
+// transaction. Reading it makes transactions fail if setConfig is
+// committed before them in the same block, like any read of a key written
+// concurrently.
+func (s *invocationStub) log() *leveledLogger {
+	if s.logger != nil {
+		return s.logger
+	}
+	// loading the configuration logs, too
+	s.logger = s.base
+	if s.base.fixed() {
+		return s.logger
+	}
+	c, err := loadConfig(s)
+	if err != nil {
+		s.logger.Warnf("unable to load configuration: %s", err)
+		return s.logger
+	}
+	s.logger = s.base.configured(c)
+	return s.logger
+}
+
+// loggerFor returns the logger of an invocation, or the global one if the
+// stub does not come from Invoke
+func loggerFor(stub shim.ChaincodeStubInterface) *leveledLogger {
+	if s, ok := stub.(*invocationStub); ok {
+		return s.log()
+	}
+	return logger
+}
+
+// success responds with a payload, telling whether the function is a
+// transaction
+func success(payload []byte, mutates bool) pb.Response {
+	res := shim.Success(payload)
+	res.Message = responseQuery
+	if mutates {
+		res.Message = responseTransaction
+	}
+	return res
+}
+
+// refuse reports a write of a query, which is a bug of its handler
+func (s *invocationStub) refuse(op string) error {
+	s.log().Errorf("%s refused, function does not change state", op)
+	return errReadOnly
+}
+
+func (s *invocationStub) PutState(key string, value []byte) error {
+	if s.readOnly {
+		return s.refuse("PutState")
+	}
+	return s.ChaincodeStubInterface.PutState(key, value)
+}
+
+func (s *invocationStub) DelState(key string) error {
+	if s.readOnly {
+		return s.refuse("DelState")
+	}
+	return s.ChaincodeStubInterface.DelState(key)
+}
+
+func (s *invocationStub) SetStateValidationParameter(key string, ep []byte) error {
+	if s.readOnly {
+		return s.refuse("SetStateValidationParameter")
+	}
+	return s.ChaincodeStubInterface.SetStateValidationParameter(key, ep)
+}
+
+func (s *invocationStub) PutPrivateData(collection string, key string, value []byte) error {
+	if s.readOnly {
+		return s.refuse("PutPrivateData")
+	}
+	return s.ChaincodeStubInterface.PutPrivateData(collection, key, value)
+}
+
+func (s *invocationStub) DelPrivateData(collection, key string) error {
+	if s.readOnly {
+		return s.refuse("DelPrivateData")
+	}
+	return s.ChaincodeStubInterface.DelPrivateData(collection, key)
+}
+
+func (s *invocationStub) SetPrivateDataValidationParameter(collection, key string, ep []byte) error {
+	if s.readOnly {
+		return s.refuse("SetPrivateDataValidationParameter")
+	}
+	return s.ChaincodeStubInterface.SetPrivateDataValidationParameter(collection, key, ep)
+}
+
+func (s *invocationStub) SetEvent(name string, payload []byte) error {
+	if s.readOnly {
+		return s.refuse("SetEvent")
+	}
+	return s.ChaincodeStubInterface.SetEvent(name, payload)
+}
+
+// internalError is returned instead of a handler's panic. The panic and its
+// stack are logged with the transaction ID.
+type internalError struct {
+	function string
+	txID     string
+}
+
+func (e internalError) Error() string {
+	return fmt.Sprintf("internal error: function=%s tx=%s", e.function, e.txID)
+}
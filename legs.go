@@ -0,0 +1,73 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"time"
+)
+
+// ensureLegs gives shipments submitted without legs a single leg
+// handled by the shipper.
+func (s *Shipment) ensureLegs() {
+	if len(s.Legs) > 0 {
+		return
+	}
+	leg := Leg{
+		CarrierID: s.ShipperID,
+		Status:    LegPlanned,
+	}
+	switch s.Status {
+	case ShipmentInTransit:
+		leg.Status = LegDeparted
+	case ShipmentDelivered:
+		leg.Status = LegArrived
+	}
+	s.Legs = []Leg{leg}
+}
+
+// deriveStatus sets the status of a shipment from its legs: submitted
+// until the first leg departs, delivered when the last leg arrived and
+// in transit in between.
+func (s *Shipment) deriveStatus() {
+	s.ensureLegs()
+
+	first, last := s.Legs[0], s.Legs[len(s.Legs)-1]
+	switch {
+	case last.Status == LegArrived:
+		s.Status = ShipmentDelivered
+		if last.ActualArrival != nil {
+			s.DelivererAt = *last.ActualArrival
+		}
+	case first.Status == LegPlanned:
+		s.Status = ShipmentSubmitted
+	default:
+		s.Status = ShipmentInTransit
+	}
+}
+
+// legAt returns the index of the leg whose carrier has custody at the given
+// time: the last leg departed at or before that time, or the first leg if
+// none has departed yet.
+func (s *Shipment) legAt(t time.Time) int {
+	s.ensureLegs()
+
+	idx := 0
+	for i, leg := range s.Legs {
+		if leg.ActualDeparture != nil && !leg.ActualDeparture.After(t) {
+			idx = i
+		}
+	}
+	return idx
+}
+
+// isCarrier checks whether a ShipmentCo carries any leg of the shipment
+func (s *Shipment) isCarrier(shipmentCoID string) bool {
+	s.ensureLegs()
+
+	for _, leg := range s.Legs {
+		if leg.CarrierID == shipmentCoID {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,116 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	loadContainerSchema = `
+{
+	"$id": "PreciousCargoShippping:loadContainerSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Container"
+		},
+		"shipment": {
+			"type": "string",
+			"description": "ID of Shipment to load"
+		}
+	},
+	"required": [ "id", "shipment" ]
+}
+`
+	loadContainerSchemaLoader = gojsonschema.NewStringLoader(loadContainerSchema)
+)
+
+// Loads a shipment into a container, which must not be sealed yet.
+// Only the carrier having custody of the shipment may load it.
+type loadContainerArg struct {
+	ID       string `json:"id"`
+	Shipment string `json:"shipment"`
+}
+
+type loadContainerInvocation struct {
+	eventRecorder
+
+	arg loadContainerArg
+
+	container *Container
+	shipment  *Shipment
+
+	// returns the updated container
+	res Container
+}
+
+func (inv *loadContainerInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter loadContainerInvocation.checkParseArguments")
+
+	inv.arg = loadContainerArg{}
+	err := parseArgument(stub, loadContainerSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.container, err = getContainer(stub, inv.arg.ID)
+	if err != nil {
+		return err
+	}
+	if len(inv.container.intactSeals()) > 0 {
+		return errors.New("container is sealed")
+	}
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.Shipment)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("invalid shipment argument: Not found")
+	}
+	inv.shipment = x.(*Shipment)
+	if err = inv.shipment.checkActive(); err != nil {
+		return fmt.Errorf("invalid shipment argument: %s", err)
+	}
+	if inv.shipment.Status == ShipmentDelivered {
+		return errors.New("invalid shipment argument: Delivered already")
+	}
+	if inv.shipment.ContainerID != "" {
+		return fmt.Errorf("invalid shipment argument: Loaded into container %s already", inv.shipment.ContainerID)
+	}
+
+	return nil
+}
+
+func (inv *loadContainerInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter loadContainerInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	if err = checkCallerHasCustody(stub, inv.shipment, now); err != nil {
+		return err
+	}
+
+	inv.shipment.ContainerID = inv.container.ID.ID
+	if _, err = saveShipment(stub, &inv.eventRecorder, inv.shipment); err != nil {
+		return err
+	}
+	inv.container.ShipmentIDs = append(inv.container.ShipmentIDs, inv.shipment.ID.ID)
+	if err = containerRegistry().update(stub, inv.container.ID.ID, inv.container); err != nil {
+		return err
+	}
+	inv.res = *inv.container
+
+	return nil
+}
+
+func (inv *loadContainerInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
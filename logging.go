@@ -0,0 +1,341 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Log lines have a level, and are written as text or JSON with fields
+// such as the transaction ID. Values of struct fields tagged pii:"true"
+// are left out of lines.
+//
+// The level and format are taken from the environment, if set there,
+// otherwise from the chaincode configuration, see setConfig.
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevelNames = []string{"debug", "info", "warn", "error"}
+
+func (l logLevel) String() string {
+	return logLevelNames[l]
+}
+
+func parseLogLevel(s string) (logLevel, error) {
+	for i, name := range logLevelNames {
+		if strings.EqualFold(s, name) {
+			return logLevel(i), nil
+		}
+	}
+	return levelInfo, fmt.Errorf("invalid log level %q, expecting one of %s", s, strings.Join(logLevelNames, ", "))
+}
+
+// log formats
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// environment variables overriding the configuration
+const (
+	logLevelEnv  = "PCS_LOG_LEVEL"
+	logFormatEnv = "PCS_LOG_FORMAT"
+)
+
+// replaces values of PII fields
+const redactedValue = "[redacted]"
+
+// logOutput is shared by all loggers derived from one another
+type logOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+
+	// level and format are fixed by the environment or the command line,
+	// configuration does not change them
+	fixedLevel, fixedFormat bool
+}
+
+// leveledLogger writes lines of its level and above
+type leveledLogger struct {
+	out    *logOutput
+	level  logLevel
+	format string
+	fields []string // pairs of keys and values
+}
+
+func newLogger(w io.Writer) *leveledLogger {
+	l := &leveledLogger{
+		out:    &logOutput{w: w},
+		level:  levelInfo,
+		format: logFormatText,
+		fields: []string{"ns", ns},
+	}
+	if s := os.Getenv(logLevelEnv); s != "" {
+		level, err := parseLogLevel(s)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", logLevelEnv, err)
+		} else {
+			l.level, l.out.fixedLevel = level, true
+		}
+	}
+	if s := os.Getenv(logFormatEnv); s != "" {
+		if s != logFormatText && s != logFormatJSON {
+			fmt.Fprintf(os.Stderr, "%s: invalid log format %q, expecting %s or %s\n", logFormatEnv, s, logFormatText, logFormatJSON)
+		} else {
+			l.format, l.out.fixedFormat = s, true
+		}
+	}
+	return l
+}
+
+// with returns a logger adding the given keys and values to each line
+func (l *leveledLogger) with(keysAndValues ...string) *leveledLogger {
+	c := *l
+	c.fields = append(append([]string{}, l.fields...), keysAndValues...)
+	return &c
+}
+
+// configured returns a logger with level and format of a configuration,
+// unless they are fixed
+func (l *leveledLogger) configured(c *Config) *leveledLogger {
+	res := *l
+	if level, err := parseLogLevel(c.LogLevel); err == nil && c.LogLevel != "" && !l.out.fixedLevel {
+		res.level = level
+	}
+	if c.LogFormat != "" && !l.out.fixedFormat {
+		res.format = c.LogFormat
+	}
+	return &res
+}
+
+// fixed tells whether configuration changes neither level nor format
+func (l *leveledLogger) fixed() bool {
+	return l.out.fixedLevel && l.out.fixedFormat
+}
+
+// SetOutput changes where this logger and all loggers derived from it write
+func (l *leveledLogger) SetOutput(w io.Writer) {
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w = w
+}
+
+// SetLevel fixes the level, configuration does not change it then
+func (l *leveledLogger) SetLevel(level logLevel) {
+	l.level, l.out.fixedLevel = level, true
+}
+
+func (l *leveledLogger) Debug(a ...interface{})                 { l.log(levelDebug, "", a) }
+func (l *leveledLogger) Debugf(format string, a ...interface{}) { l.log(levelDebug, format, a) }
+func (l *leveledLogger) Info(a ...interface{})                  { l.log(levelInfo, "", a) }
+func (l *leveledLogger) Infof(format string, a ...interface{})  { l.log(levelInfo, format, a) }
+func (l *leveledLogger) Warn(a ...interface{})                  { l.log(levelWarn, "", a) }
+func (l *leveledLogger) Warnf(format string, a ...interface{})  { l.log(levelWarn, format, a) }
+func (l *leveledLogger) Error(a ...interface{})                 { l.log(levelError, "", a) }
+func (l *leveledLogger) Errorf(format string, a ...interface{}) { l.log(levelError, format, a) }
+
+// Fatalf logs an error and exits
+func (l *leveledLogger) Fatalf(format string, a ...interface{}) {
+	l.log(levelError, format, a)
+	os.Exit(1)
+}
+
+func (l *leveledLogger) log(level logLevel, format string, a []interface{}) {
+	if level < l.level {
+		return
+	}
+	for i := range a {
+		a[i] = redacted(a[i])
+	}
+	msg := ""
+	if format == "" {
+		msg = fmt.Sprint(a...)
+	} else {
+		msg = fmt.Sprintf(format, a...)
+	}
+	msg = strings.TrimSuffix(msg, "\n")
+	at := time.Now().UTC().Format("2006-01-02T15:04:05.000000Z")
+
+	var b bytes.Buffer
+	if l.format == logFormatJSON {
+		line := map[string]string{"time": at, "level": level.String(), "msg": msg}
+		for i := 0; i+1 < len(l.fields); i += 2 {
+			line[l.fields[i]] = l.fields[i+1]
+		}
+		data, err := json.Marshal(line)
+		if err != nil {
+			return
+		}
+		b.Write(data)
+	} else {
+		fmt.Fprintf(&b, "%s %-5s %s", at, strings.ToUpper(level.String()), msg)
+		for i := 0; i+1 < len(l.fields); i += 2 {
+			fmt.Fprintf(&b, " %s=%s", l.fields[i], quoteLogValue(l.fields[i+1]))
+		}
+	}
+	b.WriteByte('\n')
+
+	l.out.mu.Lock()
+	defer l.out.mu.Unlock()
+	l.out.w.Write(b.Bytes())
+}
+
+// quoteLogValue quotes values which would not read as one otherwise
+func quoteLogValue(v string) string {
+	if v == "" || strings.ContainsAny(v, " \t\r\n\"=") {
+		return strconv.Quote(v)
+	}
+	return v
+}
+
+// redacted returns values with PII as JSON leaving out the PII, and other
+// values as they are
+func redacted(v interface{}) interface{} {
+	if v == nil || !containsPII(reflect.TypeOf(v), map[reflect.Type]bool{}) {
+		return v
+	}
+	data, err := json.Marshal(redactValue(reflect.ValueOf(v)))
+	if err != nil {
+		return redactedValue
+	}
+	return string(data)
+}
+
+var piiTypes sync.Map // reflect.Type to bool
+
+// containsPII tells whether a type has fields tagged pii:"true", directly
+// or in nested types
+func containsPII(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if res, found := piiTypes.Load(t); found {
+		return res.(bool)
+	}
+	if seen[t] {
+		return false
+	}
+	seen[t] = true
+
+	res := false
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		res = containsPII(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField() && !res; i++ {
+			f := t.Field(i)
+			res = f.Tag.Get("pii") == "true" || containsPII(f.Type, seen)
+		}
+	}
+	piiTypes.Store(t, res)
+	return res
+}
+
+// redactValue converts a value into one which marshals like it, with PII
+// fields replaced
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() || !v.CanInterface() {
+		return nil
+	}
+	if !containsPII(v.Type(), map[reflect.Type]bool{}) {
+		return v.Interface()
+	}
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		res := []interface{}{}
+		for i := 0; i < v.Len(); i++ {
+			res = append(res, redactValue(v.Index(i)))
+		}
+		return res
+	case reflect.Map:
+		res := map[string]interface{}{}
+		for _, k := range v.MapKeys() {
+			res[fmt.Sprint(k.Interface())] = redactValue(v.MapIndex(k))
+		}
+		return res
+	case reflect.Struct:
+		res := map[string]interface{}{}
+		redactStruct(v, res)
+		return res
+	}
+	return v.Interface()
+}
+
+// redactStruct adds the fields of a struct to a map by their JSON names,
+// including those of embedded structs
+func redactStruct(v reflect.Value, res map[string]interface{}) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag := f.Tag.Get("json"); tag != "" {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+		fv := v.Field(i)
+		if f.Anonymous && fv.Kind() == reflect.Struct && f.Tag.Get("json") == "" {
+			redactStruct(fv, res)
+			continue
+		}
+		if !fv.CanInterface() {
+			continue
+		}
+		if strings.Contains(opts, "omitempty") && isEmptyValue(fv) {
+			continue
+		}
+		if f.Tag.Get("pii") == "true" {
+			if !isEmptyValue(fv) {
+				res[name] = redactedValue
+			}
+			continue
+		}
+		res[name] = redactValue(fv)
+	}
+}
+
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+	return false
+}
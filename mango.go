@@ -0,0 +1,149 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Clients may send Mango selectors for rich queries, but only a restricted
+// subset: whitelisted fields, comparison operators and a limited nesting
+// depth. Operators like $regex or $where, which can be expensive or
+// reach into unrelated data, are refused.
+
+const maxSelectorDepth = 6
+
+// mangoFields whitelists fields of a document type. Scalar fields are
+// compared directly, array fields only with $elemMatch, against the
+// fields of their elements or, if there are none, the elements themselves.
+type mangoFields struct {
+	scalar map[string]bool
+	array  map[string]map[string]bool
+}
+
+func fieldSet(fields ...string) map[string]bool {
+	res := map[string]bool{}
+	for _, f := range fields {
+		res[f] = true
+	}
+	return res
+}
+
+// operators comparing a field with a single value
+var mangoValueOperators = fieldSet("$eq", "$ne", "$gt", "$gte", "$lt", "$lte")
+
+// checkSelector makes sure a selector only uses whitelisted fields
+// and operators.
+func (f mangoFields) checkSelector(selector interface{}) error {
+	return f.checkCombination(selector, f.scalar, 0)
+}
+
+// checkCombination checks a selector object of fields and logical
+// operators. fields are the scalar fields allowed here, array fields are
+// taken from f, which is empty within $elemMatch.
+func (f mangoFields) checkCombination(selector interface{}, fields map[string]bool, depth int) error {
+	if depth > maxSelectorDepth {
+		return errors.New("selector is nested too deeply")
+	}
+	m, ok := selector.(map[string]interface{})
+	if !ok {
+		return errors.New("selector must be an object")
+	}
+	for key, value := range m {
+		switch key {
+		case "$and", "$or", "$nor":
+			list, ok := value.([]interface{})
+			if !ok || len(list) == 0 {
+				return fmt.Errorf("%s expects a non-empty array", key)
+			}
+			for _, item := range list {
+				if err := f.checkCombination(item, fields, depth+1); err != nil {
+					return err
+				}
+			}
+		case "$not":
+			if err := f.checkCombination(value, fields, depth+1); err != nil {
+				return err
+			}
+		default:
+			if strings.HasPrefix(key, "$") {
+				return fmt.Errorf("operator %s is not allowed", key)
+			}
+			if fields[key] {
+				if err := checkCondition(key, value); err != nil {
+					return err
+				}
+				continue
+			}
+			elementFields, ok := f.array[key]
+			if !ok {
+				return fmt.Errorf("field %s is not allowed", key)
+			}
+			if err := f.checkElemMatch(key, value, elementFields, depth+1); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// checkElemMatch checks the condition on an array field
+func (f mangoFields) checkElemMatch(field string, value interface{}, elementFields map[string]bool, depth int) error {
+	m, ok := value.(map[string]interface{})
+	if !ok || len(m) != 1 || m["$elemMatch"] == nil {
+		return fmt.Errorf("field %s can only be matched by $elemMatch", field)
+	}
+	if len(elementFields) == 0 {
+		// elements are scalars
+		return checkCondition(field, m["$elemMatch"])
+	}
+	return mangoFields{}.checkCombination(m["$elemMatch"], elementFields, depth)
+}
+
+// checkCondition checks the condition on a scalar field, either a value
+// or an object of operators.
+func checkCondition(field string, value interface{}) error {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return checkScalar(field, value)
+	}
+	if len(m) == 0 {
+		return fmt.Errorf("field %s: empty condition", field)
+	}
+	for op, operand := range m {
+		switch {
+		case mangoValueOperators[op]:
+			if err := checkScalar(field, operand); err != nil {
+				return err
+			}
+		case op == "$in" || op == "$nin":
+			list, ok := operand.([]interface{})
+			if !ok || len(list) == 0 || len(list) > 100 {
+				return fmt.Errorf("field %s: %s expects an array of 1 to 100 values", field, op)
+			}
+			for _, item := range list {
+				if err := checkScalar(field, item); err != nil {
+					return err
+				}
+			}
+		case op == "$exists":
+			if _, ok := operand.(bool); !ok {
+				return fmt.Errorf("field %s: $exists expects a boolean", field)
+			}
+		default:
+			return fmt.Errorf("field %s: operator %s is not allowed", field, op)
+		}
+	}
+	return nil
+}
+
+func checkScalar(field string, value interface{}) error {
+	switch value.(type) {
+	case string, json.Number, float64, bool, nil:
+		return nil
+	}
+	return fmt.Errorf("field %s: expecting a string, number, boolean or null", field)
+}
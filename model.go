@@ -7,29 +7,66 @@ import (
 	"time"
 )
 
-// World State/Ledger data structs
+// World State/Ledger data structs. Fields tagged pii:"true" hold personal
+// data, which is left out of logs.
 
 // ID is a generic identifier
 type ID struct {
 	ID string `json:"id"`
 }
 
+// Participant status values. Participants registered before there was
+// a status are active.
+const (
+	ParticipantActive      = "active"
+	ParticipantDeactivated = "deactivated"
+)
+
 // Participant is a simple Participant identified by Id and a name
 type Participant struct {
 	ID
-	Name string `json:"name"`
+	Name string `json:"name" pii:"true"`
+
+	// ID of the certificate the participant registered with
+	Identity string `json:"identity,omitempty" pii:"true"`
+
+	Status             string     `json:"status,omitempty"`
+	UpdatedAt          *time.Time `json:"updatedAt,omitempty"`
+	DeactivatedAt      *time.Time `json:"deactivatedAt,omitempty"`
+	DeactivationReason string     `json:"deactivationReason,omitempty"`
+}
+
+func (p Participant) active() bool {
+	return p.Status != ParticipantDeactivated
 }
 
 // IndividualParticipant has an address
 type IndividualParticipant struct {
 	Participant
-	Address string `json:"address"` // simplified address field as one-liner
+	Address Address `json:"address" pii:"true"`
 }
 
 // ShipmentCo is a Shipment Company
 type ShipmentCo struct {
 	Participant
-	Address string `json:"address"`
+	Address Address `json:"address"`
+}
+
+// ContactPerson of a CorporateParticipant
+type ContactPerson struct {
+	Name  string `json:"name" pii:"true"`
+	Email string `json:"email,omitempty" pii:"true"`
+	Phone string `json:"phone,omitempty" pii:"true"`
+	Role  string `json:"role,omitempty"`
+}
+
+// CorporateParticipant is a company sending or receiving shipments
+type CorporateParticipant struct {
+	Participant
+	Address            Address         `json:"address"`
+	RegistrationNumber string          `json:"registrationNumber"` // company register
+	VATID              string          `json:"vatId,omitempty"`
+	Contacts           []ContactPerson `json:"contacts,omitempty"`
 }
 
 // Asset is identified by Id
@@ -37,30 +74,266 @@ type Asset struct {
 	ID
 }
 
+// Shipment status values
+const (
+	ShipmentSubmitted    = "submitted"
+	ShipmentInTransit    = "in-transit"
+	ShipmentDelivered    = "delivered"
+	ShipmentSplit        = "split"        // continued by its children
+	ShipmentConsolidated = "consolidated" // continued by its parent
+)
+
+// ManifestItem is a line of a shipment's manifest
+type ManifestItem struct {
+	SKU         string `json:"sku"`
+	Description string `json:"description,omitempty"`
+	Quantity    int    `json:"quantity"`
+}
+
+// Leg status values
+const (
+	LegPlanned  = "planned"
+	LegDeparted = "departed"
+	LegArrived  = "arrived"
+)
+
+// Leg is a part of a shipment's journey, handled by a single carrier
+// between two hubs.
+type Leg struct {
+	CarrierID      string `json:"carrier"` // ID of ShipmentCo
+	OriginHub      string `json:"originHub"`
+	DestinationHub string `json:"destinationHub"`
+
+	PlannedDeparture time.Time  `json:"plannedDeparture"`
+	PlannedArrival   time.Time  `json:"plannedArrival"`
+	ActualDeparture  *time.Time `json:"actualDeparture,omitempty"`
+	ActualArrival    *time.Time `json:"actualArrival,omitempty"`
+
+	Status string `json:"status"`
+
+	// intact seal numbers of the shipment's container when the leg
+	// departed and arrived, to detect seals changed between handovers
+	SealsAtDeparture []string `json:"sealsAtDeparture"`
+	SealsAtArrival   []string `json:"sealsAtArrival"`
+}
+
+// Shipment flag types
+const (
+	FlagSealChanged  = "sealChanged"
+	FlagSealTampered = "sealTampered"
+)
+
+// ShipmentFlag marks something that needs attention, e.g. a suspected
+// tampering with the shipment's container.
+type ShipmentFlag struct {
+	Type    string    `json:"type"`
+	At      time.Time `json:"at"`
+	Details string    `json:"details"`
+}
+
 // Shipment combines Shipper, From and To Participants and
-// Status
+// Status. The shipper is the contracting carrier, the legs
+// describe who actually carries the shipment when.
 type Shipment struct {
 	Asset
 
+	// document type for CouchDB rich queries, set by saveShipment
+	DocType string `json:"docType,omitempty"`
+
 	ShipperID string `json:"by"`
 	FromID    string `json:"from"`
 	ToID      string `json:"to"`
 
-	Status string `json:"status"`
+	Status string `json:"status"` // derived from legs
+	Legs   []Leg  `json:"legs,omitempty"`
+
+	// IDs of tracking devices assigned to this shipment
+	DeviceIDs []string `json:"devices,omitempty"`
+
+	Manifest []ManifestItem `json:"manifest,omitempty"`
+
+	// A split shipment is the parent of its parts, a consolidated
+	// shipment is the parent of the shipments it contains.
+	ParentID string   `json:"parent,omitempty"`
+	ChildIDs []string `json:"children,omitempty"`
+
+	// ID of the container the shipment is loaded into
+	ContainerID string         `json:"container,omitempty"`
+	Flags       []ShipmentFlag `json:"flags,omitempty"`
 
 	SubmittedAt time.Time `json:"submittime"`
 	DelivererAt time.Time `json:"delivertime,omitempty"`
+
+	// aggregated tracking data, nil until first data point arrives
+	Summary *TrackingSummary `json:"summary,omitempty"`
+
+	// planned route, tracking data is evaluated against it
+	Route      *PlannedRoute `json:"route,omitempty"`
+	RouteState *RouteState   `json:"routeState,omitempty"`
+}
+
+// GeoPoint is a position in WGS84 degrees
+type GeoPoint struct {
+	Latitude  float64 `json:"lat"`
+	Longitude float64 `json:"lng"`
+}
+
+// Geofence is a named area, given as a polygon
+type Geofence struct {
+	Name    string     `json:"name"`
+	Polygon []GeoPoint `json:"polygon"`
+}
+
+// PlannedRoute describes where a shipment is supposed to go. Origin,
+// destination and waypoints are geofences, the corridor is a polyline
+// which a shipment must not leave by more than half its width.
+type PlannedRoute struct {
+	Origin      *Geofence  `json:"origin,omitempty"`
+	Destination *Geofence  `json:"destination,omitempty"`
+	Waypoints   []Geofence `json:"waypoints,omitempty"`
+
+	Corridor        []GeoPoint `json:"corridor,omitempty"`
+	CorridorWidthKm float64    `json:"corridorWidthKm,omitempty"`
+}
+
+// RouteState is the result of evaluating tracking data against
+// a planned route, as of the latest data point evaluated.
+type RouteState struct {
+	Inside      []string  `json:"inside"` // names of geofences
+	OffRoute    bool      `json:"offRoute"`
+	EvaluatedAt time.Time `json:"evaluatedAt"`
+}
+
+// Geofence event types
+const (
+	GeofenceEnter    = "enter"
+	GeofenceExit     = "exit"
+	RouteOffRoute    = "offRoute"
+	RouteBackOnRoute = "backOnRoute"
+)
+
+// GeofenceEvent records a shipment entering or leaving a geofence
+// or its route corridor.
+type GeofenceEvent struct {
+	ShipmentID string    `json:"shipmentId"`
+	Type       string    `json:"type"`
+	Geofence   string    `json:"geofence,omitempty"`
+	At         time.Time `json:"at"`
+	Position   GeoPoint  `json:"position"`
+	DeviceID   string    `json:"device"`
+}
+
+// TrackingSummary holds running aggregates of all tracking data
+// points of a shipment
+type TrackingSummary struct {
+	Count     int       `json:"count"`
+	FirstSeen time.Time `json:"firstSeen"`
+	LastSeen  time.Time `json:"lastSeen"`
+
+	MinTemperature float32 `json:"minTemp"`
+	MaxTemperature float32 `json:"maxTemp"`
+	AvgTemperature float32 `json:"avgTemp"`
+	MinHumidity    float32 `json:"minHum"`
+	MaxHumidity    float32 `json:"maxHum"`
+	AvgHumidity    float32 `json:"avgHum"`
+
+	// distance between consecutive points, in order of measurement time
+	DistanceKm   float64  `json:"distanceKm"`
+	LastPosition GeoPoint `json:"lastPosition"`
 }
 
 // TrackingDataPoint combines a location and environmental
 // parameters for a shipment, at a point in time.
 type TrackingDataPoint struct {
 	ShipmentID  ID        `json:"shipmentId"`
+	DeviceID    string    `json:"device"`
+	Counter     uint64    `json:"counter"` // monotonic per device
 	At          time.Time `json:"at"`
 	Latitude    float64   `json:"lat"`
 	Longitude   float64   `json:"lng"`
 	Temperature float32   `json:"temp"`
 	Humidity    float32   `json:"hum"`
+
+	// base64 signature by the device over the other fields, see
+	// signedPayloadV1
+	Signature string `json:"sig,omitempty"`
+}
+
+// Device status values
+const (
+	DeviceActive  = "active"
+	DeviceRevoked = "revoked"
+)
+
+// Device is an IoT tracker owned by a ShipmentCo. Only active devices
+// assigned to a shipment may deliver tracking data for it.
+type Device struct {
+	Asset
+
+	OwnerID      string    `json:"owner"`     // ID of ShipmentCo
+	PublicKey    string    `json:"publicKey"` // PEM encoded
+	Model        string    `json:"model"`
+	CalibratedAt time.Time `json:"calibrationDate"`
+
+	Status       string     `json:"status"`
+	RegisteredAt time.Time  `json:"registertime"`
+	RevokedAt    *time.Time `json:"revoketime,omitempty"`
+
+	// highest counter value seen in signed tracking data, used
+	// to detect replayed readings
+	LastCounter uint64 `json:"lastCounter"`
+}
+
+// Seal status values
+const (
+	SealIntact = "intact"
+	SealBroken = "broken"
+)
+
+// Seal is a tamper-evident seal of a container, identified by its number
+type Seal struct {
+	Number   string     `json:"number"`
+	Status   string     `json:"status"`
+	SealedAt time.Time  `json:"sealedAt"`
+	BrokenAt *time.Time `json:"brokenAt,omitempty"`
+}
+
+// Container is a unit shipments are loaded into, e.g. a sea container
+// or an air cargo ULD, owned by a ShipmentCo.
+type Container struct {
+	Asset
+
+	Type        string   `json:"type"`
+	OwnerID     string   `json:"owner"` // ID of ShipmentCo
+	Seals       []Seal   `json:"seals,omitempty"`
+	ShipmentIDs []string `json:"shipments,omitempty"`
+}
+
+// Seal check actions
+const (
+	SealActionSealed    = "sealed"
+	SealActionInspected = "inspected"
+	SealActionBroken    = "broken"
+)
+
+// SealCheck records who sealed, inspected or broke which seal when
+type SealCheck struct {
+	ContainerID string    `json:"containerId"`
+	SealNumber  string    `json:"seal"`
+	Action      string    `json:"action"`
+	Intact      bool      `json:"intact"`
+	By          string    `json:"by" pii:"true"` // identity of caller
+	At          time.Time `json:"at"`
+	Remarks     string    `json:"remarks,omitempty"`
+}
+
+// Config is the configuration of the chaincode, a single item changed by
+// admins
+type Config struct {
+	LogLevel  string    `json:"logLevel,omitempty"`
+	LogFormat string    `json:"logFormat,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	UpdatedBy string    `json:"updatedBy" pii:"true"` // identity of caller
 }
 
 // registries
@@ -78,16 +351,49 @@ func shipmentRegistry() registry {
 	}
 }
 
+// Participants of all kinds share one ID index, so that an ID
+// identifies a participant regardless of its kind.
 func shipmentCoRegistry() registry {
 	return registry{
-		typeStr: "ShipmentCo",
+		typeStr: KindShipmentCo,
 		typeRT:  reflect.TypeOf(&ShipmentCo{}),
+		idIndex: participantIndex,
 	}
 }
 
 func individualParticipantRegistry() registry {
 	return registry{
-		typeStr: "IndividualParticipant",
+		typeStr: KindIndividualParticipant,
 		typeRT:  reflect.TypeOf(&IndividualParticipant{}),
+		idIndex: participantIndex,
+	}
+}
+
+func corporateParticipantRegistry() registry {
+	return registry{
+		typeStr: KindCorporateParticipant,
+		typeRT:  reflect.TypeOf(&CorporateParticipant{}),
+		idIndex: participantIndex,
+	}
+}
+
+func deviceRegistry() registry {
+	return registry{
+		typeStr: "Device",
+		typeRT:  reflect.TypeOf(&Device{}),
+	}
+}
+
+func containerRegistry() registry {
+	return registry{
+		typeStr: "Container",
+		typeRT:  reflect.TypeOf(&Container{}),
+	}
+}
+
+func configRegistry() registry {
+	return registry{
+		typeStr: configType,
+		typeRT:  reflect.TypeOf(&Config{}),
 	}
 }
@@ -0,0 +1,235 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// Functions are served by the REST gateway at functionsPath + name,
+// taking the argument as request body. Some are served at REST routes
+// as well, see gatewayRoutes.
+const functionsPath = "/functions/"
+
+// openAPISchema adapts a JSON schema to OpenAPI, which does not know
+// about schema IDs.
+func openAPISchema(schema map[string]interface{}) map[string]interface{} {
+	res := map[string]interface{}{}
+	for k, v := range schema {
+		if k == "$id" || k == "$schema" {
+			continue
+		}
+		res[k] = v
+	}
+	return res
+}
+
+var openAPIErrorResponse = map[string]interface{}{
+	"description": "the function failed",
+	"content": map[string]interface{}{
+		"application/json": map[string]interface{}{
+			"schema": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"error": map[string]interface{}{"type": "string"},
+				},
+			},
+		},
+	},
+}
+
+// withoutProperties removes properties from an object schema, e.g. those
+// passed as path parameters instead.
+func withoutProperties(schema map[string]interface{}, names []string) map[string]interface{} {
+	res := openAPISchema(schema)
+	properties, _ := res["properties"].(map[string]interface{})
+	required, _ := res["required"].([]interface{})
+	if len(names) == 0 || properties == nil {
+		return res
+	}
+
+	remove := fieldSet(names...)
+	newProperties := map[string]interface{}{}
+	for name, p := range properties {
+		if !remove[name] {
+			newProperties[name] = p
+		}
+	}
+	newRequired := []interface{}{}
+	for _, name := range required {
+		if s, ok := name.(string); !ok || !remove[s] {
+			newRequired = append(newRequired, name)
+		}
+	}
+	res["properties"] = newProperties
+	if len(newRequired) > 0 {
+		res["required"] = newRequired
+	} else {
+		delete(res, "required")
+	}
+	return res
+}
+
+// openAPIOperation describes a function as an operation. Its argument is
+// taken from the JSON request body, if there is one, and parameters.
+func openAPIOperation(d functionDescription, operationID string, withBody bool, parameters []interface{}, pathParams []string) map[string]interface{} {
+	tag := "queries"
+	if d.Mutates {
+		tag = "transactions"
+	}
+	op := map[string]interface{}{
+		"operationId": operationID,
+		"summary":     d.Description,
+		"tags":        []string{tag},
+		"responses": map[string]interface{}{
+			"200": map[string]interface{}{
+				"description": "the function's response",
+				"content": map[string]interface{}{
+					"application/json": map[string]interface{}{
+						"schema": openAPISchema(d.Response),
+					},
+				},
+			},
+			"400": openAPIErrorResponse,
+		},
+		"x-pcs-function": d.Name,
+		"x-pcs-role":     d.Role,
+		"x-pcs-mutates":  d.Mutates,
+	}
+	if withBody {
+		op["requestBody"] = map[string]interface{}{
+			"required": len(pathParams) == 0,
+			"content": map[string]interface{}{
+				"application/json": map[string]interface{}{
+					"schema": withoutProperties(d.Arguments, pathParams),
+				},
+			},
+		}
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+	return op
+}
+
+// openAPIParameters describes the path and query parameters of a route
+func openAPIParameters(route gatewayRoute) []interface{} {
+	res := []interface{}{}
+	for _, name := range route.pathParams() {
+		res = append(res, map[string]interface{}{
+			"name":     name,
+			"in":       "path",
+			"required": true,
+			"schema":   map[string]interface{}{"type": "string"},
+		})
+	}
+	for _, name := range route.query {
+		res = append(res, map[string]interface{}{
+			"name":   name,
+			"in":     "query",
+			"schema": map[string]interface{}{"type": "string"},
+		})
+	}
+	return res
+}
+
+// openAPIDocument describes the REST gateway's routes for all functions
+func openAPIDocument(handlers map[string]handlerInfo, serverURL string) (map[string]interface{}, error) {
+	descriptions, err := describeHandlers(handlers)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]functionDescription{}
+	paths := map[string]interface{}{}
+	for _, d := range descriptions {
+		byName[d.Name] = d
+		paths[functionsPath+d.Name] = map[string]interface{}{
+			"post": openAPIOperation(d, d.Name, true, nil, nil),
+		}
+	}
+
+	for _, route := range gatewayRoutes {
+		d, found := byName[route.function]
+		if !found {
+			return nil, fmt.Errorf("route %s %s: unknown function %s", route.method, route.pattern, route.function)
+		}
+		method := strings.ToLower(route.method)
+		operations, ok := paths[route.pattern].(map[string]interface{})
+		if !ok {
+			operations = map[string]interface{}{}
+			paths[route.pattern] = operations
+		}
+		operations[method] = openAPIOperation(d, method+strings.ToUpper(d.Name[:1])+d.Name[1:], route.method != http.MethodGet,
+			openAPIParameters(route), route.pathParams())
+	}
+
+	paths[strings.TrimSuffix(functionsPath, "/")] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": describeFunction,
+			"summary":     "Describes all functions with argument and response schemas.",
+			"tags":        []string{"queries"},
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "descriptions of all functions",
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": typeSchema(reflect.TypeOf(struct {
+								Namespace string                `json:"namespace"`
+								Functions []functionDescription `json:"functions"`
+							}{})),
+						},
+					},
+				},
+			},
+		},
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.0",
+		"info": map[string]interface{}{
+			"title":       "PreciousCargoShipping",
+			"description": "Functions of chaincode " + ns,
+			"version":     "1.0.0",
+		},
+		"servers": []interface{}{
+			map[string]interface{}{"url": serverURL},
+		},
+		"paths": paths,
+	}, nil
+}
+
+// openapiCommand writes the OpenAPI document, without needing a peer
+func openapiCommand(args []string) error {
+	flags := flag.NewFlagSet("openapi", flag.ContinueOnError)
+	serverURL := flags.String("server", "http://localhost:8080", "URL of the REST gateway")
+	out := flags.String("o", "", "output file, stdout if not given")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	doc, err := openAPIDocument(handlers, *serverURL)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+	return e.Encode(doc)
+}
@@ -0,0 +1,186 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Participant kinds, these are the type names of their registries
+const (
+	KindIndividualParticipant = "IndividualParticipant"
+	KindCorporateParticipant  = "CorporateParticipant"
+	KindShipmentCo            = "ShipmentCo"
+)
+
+// name of the ID index shared by all participant kinds
+const participantIndex = "Participant"
+
+// participantRegistries returns the registries of the given participant
+// kinds, or of all kinds if none are given.
+func participantRegistries(kinds ...string) []registry {
+	all := []registry{
+		individualParticipantRegistry(),
+		corporateParticipantRegistry(),
+		shipmentCoRegistry(),
+	}
+	if len(kinds) == 0 {
+		return all
+	}
+	res := []registry{}
+	for _, r := range all {
+		for _, kind := range kinds {
+			if r.typeStr == kind {
+				res = append(res, r)
+			}
+		}
+	}
+	return res
+}
+
+// getAnyParticipant resolves an ID to a participant of one of the given
+// kinds, or of any kind if none are given. Returns the kind and a pointer
+// to the participant.
+//
+// Before participants shared an ID index, each kind counted on its own, so
+// an old ID may resolve to more than one participant. Restricting kinds
+// helps, otherwise the ID is reported as ambiguous.
+func getAnyParticipant(stub shim.ChaincodeStubInterface, id string, kinds ...string) (string, interface{}, error) {
+	kind := ""
+	var res interface{}
+	for _, r := range participantRegistries(kinds...) {
+		_, x, err := r.get(stub, id)
+		if err == errNotFound {
+			continue
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if res != nil {
+			return "", nil, fmt.Errorf("participant ID %s is ambiguous (%s, %s)", id, kind, r.typeStr)
+		}
+		kind, res = r.typeStr, x
+	}
+	if res == nil {
+		return "", nil, errNotFound
+	}
+	return kind, res, nil
+}
+
+// newParticipantID returns the next ID of the shared participant index.
+func newParticipantID(stub shim.ChaincodeStubInterface) (string, error) {
+	ids, err := newParticipantIDs(stub, 1)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// newParticipantIDs returns the next n IDs of the shared participant index.
+// A transaction does not see its own writes, so all IDs it needs have to
+// be taken at once. On first use, the index starts after the highest ID
+// any participant kind has counted to on its own, so that new IDs do not
+// collide with old ones.
+func newParticipantIDs(stub shim.ChaincodeStubInterface, n int) ([]string, error) {
+	ck, err := stub.CreateCompositeKey(ns, []string{".", participantIndex, ".", "index"})
+	if err != nil {
+		return nil, err
+	}
+
+	indexKeys := []string{ck}
+	for _, r := range participantRegistries() {
+		kck, err := stub.CreateCompositeKey(ns, []string{".", r.typeStr, ".", "index"})
+		if err != nil {
+			return nil, err
+		}
+		indexKeys = append(indexKeys, kck)
+	}
+
+	var lastIndex uint64
+	for i, key := range indexKeys {
+		data, err := stub.GetState(key)
+		if err != nil {
+			return nil, err
+		}
+		if data == nil {
+			continue
+		}
+		last, err := strconv.ParseUint(string(data), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		if i == 0 {
+			// shared index is in use, this is all we need
+			lastIndex = last
+			break
+		}
+		if last > lastIndex {
+			lastIndex = last
+		}
+	}
+	ids := []string{}
+	for i := 0; i < n; i++ {
+		lastIndex = lastIndex + 1
+		ids = append(ids, fmt.Sprintf("%010v", lastIndex))
+	}
+
+	err = stub.PutState(ck, []byte(strconv.FormatUint(lastIndex, 10)))
+	if err != nil {
+		return nil, err
+	}
+	loggerFor(stub).Debugf("newParticipantIDs: PutState to key=%s, index=%v", ck, lastIndex)
+
+	return ids, nil
+}
+
+// describeParticipant returns name and address of a participant of any
+// kind, e.g. for display.
+func describeParticipant(x interface{}) (string, Address) {
+	switch p := x.(type) {
+	case *IndividualParticipant:
+		return p.Name, p.Address
+	case *CorporateParticipant:
+		return p.Name, p.Address
+	case *ShipmentCo:
+		return p.Name, p.Address
+	}
+	return "", Address{}
+}
+
+// Emitted when a participant has been registered, updated or deactivated,
+// for off-chain copies of the participants. Participant is of the kind's
+// type.
+type participantSavedEvent struct {
+	Kind        string      `json:"kind"`
+	Participant interface{} `json:"participant"`
+}
+
+// participantOf returns the participant data embedded in a participant
+// of any kind, as returned by getAnyParticipant.
+func participantOf(x interface{}) *Participant {
+	switch p := x.(type) {
+	case *IndividualParticipant:
+		return &p.Participant
+	case *CorporateParticipant:
+		return &p.Participant
+	case *ShipmentCo:
+		return &p.Participant
+	}
+	return nil
+}
+
+// getActiveParticipant resolves an ID like getAnyParticipant, but fails
+// for deactivated participants.
+func getActiveParticipant(stub shim.ChaincodeStubInterface, id string, kinds ...string) (string, interface{}, error) {
+	kind, x, err := getAnyParticipant(stub, id, kinds...)
+	if err != nil {
+		return "", nil, err
+	}
+	if p := participantOf(x); p == nil || !p.active() {
+		return "", nil, fmt.Errorf("participant %s is deactivated", id)
+	}
+	return kind, x, nil
+}
@@ -18,9 +18,9 @@ package main
 import (
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"reflect"
+	"runtime/debug"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
 	pb "github.com/hyperledger/fabric/protos/peer"
@@ -28,18 +28,18 @@ import (
 
 var (
 	ns     = "sample.PreciousCargoChaincode"
-	logger = log.New(os.Stdout, fmt.Sprintf("%s: ", ns), log.Ldate|log.Ltime|log.Lmicroseconds)
+	logger = newLogger(os.Stdout)
 )
 
 // PreciousCargoChaincode is the Chaincode wrapper for PreciousCargoShipment
 type PreciousCargoChaincode struct {
-	// map function names to function implementation types
-	handlers map[string]reflect.Type
+	// map function names to function implementations
+	handlers map[string]handlerInfo
 }
 
 // Init initializes chaincode
 func (cci *PreciousCargoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Response {
-	logger.Println("enter Init")
+	logger.Debug("enter Init")
 
 	// we could process init arguments here using the stub
 
@@ -47,16 +47,31 @@ func (cci *PreciousCargoChaincode) Init(stub shim.ChaincodeStubInterface) pb.Res
 }
 
 // Invoke a chaincode function according to function namen and handlers.
-func (cci *PreciousCargoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.Response {
-	logger.Println("enter Invoke")
-
+// A panicking handler fails the invocation with an internal error instead
+// of crashing the chaincode.
+func (cci *PreciousCargoChaincode) Invoke(stub shim.ChaincodeStubInterface) (res pb.Response) {
 	function, args := stub.GetFunctionAndParameters()
-	logger.Printf("requested function=%s, with args=%#v", function, args)
+	info, found := cci.handlers[function]
+	stub = newInvocationStub(stub, function, !info.mutates)
+	// arguments may hold personal data, handlers log them redacted
+	loggerFor(stub).Infof("invoked with %d arguments", len(args))
+
+	defer func() {
+		if r := recover(); r != nil {
+			err := internalError{function: function, txID: stub.GetTxID()}
+			loggerFor(stub).Errorf("%s: panic: %v\n%s", err, r, debug.Stack())
+			res = shim.Error(err.Error())
+		}
+	}()
+
+	if function == describeFunction {
+		return cci.describe(stub)
+	}
 
-	if invType, found := cci.handlers[function]; found {
-		// from invType as reflect.Type, create a new object and
+	if found {
+		// from info.typ as reflect.Type, create a new object and
 		// cast its interface to InvocationHandler.
-		inv := reflect.New(invType).Interface().(InvocationHandler)
+		inv := reflect.New(info.typ).Interface().(InvocationHandler)
 		// let it check its input
 		if err := inv.checkParseArguments(stub); err != nil {
 			return shim.Error(err.Error())
@@ -65,30 +80,44 @@ func (cci *PreciousCargoChaincode) Invoke(stub shim.ChaincodeStubInterface) pb.R
 		if err := inv.process(stub); err != nil {
 			return shim.Error(err.Error())
 		}
+		// send out events, if any
+		if e, ok := inv.(eventEmitter); ok {
+			if err := sendEvents(stub, e.getEvents()); err != nil {
+				return shim.Error(err.Error())
+			}
+		}
 		// send out the response
 		r, err := json.Marshal(inv.getResponse(stub))
 		if err != nil {
-			logger.Println(err)
+			loggerFor(stub).Error(err)
 			return shim.Error("Internal JSON marshal error (response).")
 		}
-		return shim.Success([]byte(r))
+		return success(r, info.mutates)
 	}
 
 	return shim.Error("Invalid function name.")
 }
 
 func main() {
-	logger.Println("Instantiating chaincode.")
+	// run a command instead of the chaincode, if one is given. The peer
+	// starts chaincode with flags only.
+	if len(os.Args) > 1 {
+		if cmd, found := commands[os.Args[1]]; found {
+			// keep stdout for the command's output
+			logger.SetOutput(os.Stderr)
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %s\n", os.Args[1], err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+
+	logger.Info("Instantiating chaincode.")
 
 	cc := &PreciousCargoChaincode{
 		// all functions as InvocationHandlers
-		handlers: map[string]reflect.Type{
-			"submitShipment":                reflect.TypeOf((*submitShipmentInvocation)(nil)).Elem(),
-			"getShipment":                   reflect.TypeOf((*getShipmentInvocation)(nil)).Elem(),
-			"registerIndividualParticipant": reflect.TypeOf((*registerIndividualParticipantInvocation)(nil)).Elem(),
-			"getIndividualParticipant":      reflect.TypeOf((*getIndividualParticipantInvocation)(nil)).Elem(),
-			"registerShipmentCo":            reflect.TypeOf((*registerShipmentCoInvocation)(nil)).Elem(),
-		},
+		handlers: handlers,
 	}
 	err := shim.Start(cc)
 	if err != nil {
@@ -0,0 +1,232 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const (
+	defaultQueryShipmentsLimit = 50
+	maxQueryShipmentsLimit     = 200
+)
+
+// shipmentQueryFields whitelists the shipment fields of rich queries.
+// Times are stored in RFC3339 and compared as strings.
+var shipmentQueryFields = mangoFields{
+	scalar: fieldSet(
+		"id", "status", "by", "from", "to", "parent", "container",
+		"submittime", "delivertime",
+		"summary.count", "summary.firstSeen", "summary.lastSeen",
+		"summary.minTemp", "summary.maxTemp", "summary.avgTemp",
+		"summary.minHum", "summary.maxHum", "summary.avgHum",
+		"summary.distanceKm", "routeState.offRoute",
+	),
+	array: map[string]map[string]bool{
+		"legs":     fieldSet("carrier", "originHub", "destinationHub", "status", "plannedDeparture", "plannedArrival"),
+		"flags":    fieldSet("type", "at"),
+		"devices":  nil,
+		"children": nil,
+	},
+}
+
+// fields which may be returned, in addition to the scalar query fields
+var shipmentProjectionFields = fieldSet(
+	"legs", "flags", "devices", "children", "manifest", "summary", "route", "routeState",
+)
+
+var (
+	queryShipmentsSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:queryShipmentsSchema",
+	"type": "object",
+	"properties": {
+		"selector": {
+			"type": "object",
+			"description": "Mango selector, restricted to whitelisted fields and operators"
+		},
+		"fields": {
+			"type": "array",
+			"items": { "type": "string" },
+			"maxItems": 32
+		},
+		"sort": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"minProperties": 1,
+				"maxProperties": 1,
+				"additionalProperties": { "enum": [ "asc", "desc" ] }
+			},
+			"maxItems": 3
+		},
+		"limit": {
+			"type": "integer",
+			"minimum": 1,
+			"maximum": %d
+		},
+		"bookmark": {
+			"type": "string",
+			"description": "bookmark of the previous page"
+		}
+	},
+	"required": [ "selector" ],
+	"additionalProperties": false
+}
+`, maxQueryShipmentsLimit)
+	queryShipmentsSchemaLoader = gojsonschema.NewStringLoader(queryShipmentsSchema)
+)
+
+// Searches shipments by a Mango selector. Needs CouchDB as state database.
+type queryShipmentsArg struct {
+	Selector json.RawMessage     `json:"selector"`
+	Fields   []string            `json:"fields"`
+	Sort     []map[string]string `json:"sort"`
+	Limit    int32               `json:"limit"`
+	Bookmark string              `json:"bookmark"`
+}
+
+// Returns matching shipments, restricted to the requested fields. If
+// bookmark is set, there may be more.
+type queryShipmentsResult struct {
+	Shipments []json.RawMessage `json:"shipments"`
+	Count     int32             `json:"count"`
+	Bookmark  string            `json:"bookmark,omitempty"`
+}
+
+type queryShipmentsInvocation struct {
+	arg queryShipmentsArg
+	res queryShipmentsResult
+
+	query string
+}
+
+func (inv *queryShipmentsInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter queryShipmentsInvocation.checkParseArguments")
+
+	inv.arg = queryShipmentsArg{}
+	if err := parseArgument(stub, queryShipmentsSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+	if inv.arg.Limit == 0 {
+		inv.arg.Limit = defaultQueryShipmentsLimit
+	}
+
+	var err error
+	inv.query, err = inv.arg.buildQuery()
+	if err != nil {
+		return err
+	}
+	loggerFor(stub).Debugf("query=%s", inv.query)
+	return nil
+}
+
+// buildQuery checks the argument against the whitelists and combines it
+// into a query for shipment documents.
+func (arg queryShipmentsArg) buildQuery() (string, error) {
+	d := json.NewDecoder(bytes.NewReader(arg.Selector))
+	d.UseNumber()
+	var selector interface{}
+	if err := d.Decode(&selector); err != nil {
+		return "", errors.New("invalid selector argument: Invalid JSON")
+	}
+	if err := shipmentQueryFields.checkSelector(selector); err != nil {
+		return "", fmt.Errorf("invalid selector argument: %s", err)
+	}
+
+	query := map[string]interface{}{
+		"selector": map[string]interface{}{
+			"$and": []interface{}{
+				map[string]interface{}{"docType": shipmentDocType},
+				selector,
+			},
+		},
+	}
+
+	if len(arg.Fields) > 0 {
+		fields := []string{"id"}
+		for _, field := range arg.Fields {
+			if !shipmentQueryFields.scalar[field] && !shipmentProjectionFields[field] {
+				return "", fmt.Errorf("invalid fields argument: field %s is not allowed", field)
+			}
+			if field != "id" {
+				fields = append(fields, field)
+			}
+		}
+		query["fields"] = fields
+	}
+
+	if len(arg.Sort) > 0 {
+		// CouchDB sorts by an index, and all indexes start with docType
+		direction := ""
+		sort := []map[string]string{}
+		for _, s := range arg.Sort {
+			for field, dir := range s {
+				if !shipmentQueryFields.scalar[field] {
+					return "", fmt.Errorf("invalid sort argument: field %s is not allowed", field)
+				}
+				if direction != "" && dir != direction {
+					return "", errors.New("invalid sort argument: all fields must be sorted in the same direction")
+				}
+				direction = dir
+				sort = append(sort, map[string]string{field: dir})
+			}
+		}
+		query["sort"] = append([]map[string]string{{"docType": direction}}, sort...)
+	}
+
+	data, err := json.Marshal(query)
+	if err != nil {
+		logger.Error(err)
+		return "", errors.New("internal JSON marshal error")
+	}
+	return string(data), nil
+}
+
+func (inv *queryShipmentsInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter queryShipmentsInvocation.process")
+
+	iter, meta, err := stub.GetQueryResultWithPagination(inv.query, inv.arg.Limit, inv.arg.Bookmark)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		// LevelDB has no rich queries
+		if strings.Contains(strings.ToLower(err.Error()), "not supported for leveldb") {
+			return errors.New("queryShipments needs CouchDB as state database, use findShipments instead")
+		}
+		return errors.New("internal error executing query")
+	}
+	if iter == nil {
+		// test stubs have no rich queries either
+		return errors.New("queryShipments needs CouchDB as state database, use findShipments instead")
+	}
+	defer iter.Close()
+
+	inv.res = queryShipmentsResult{
+		Shipments: []json.RawMessage{},
+	}
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading query result")
+		}
+		inv.res.Shipments = append(inv.res.Shipments, json.RawMessage(kv.Value))
+	}
+	inv.res.Count = int32(len(inv.res.Shipments))
+	if meta != nil && inv.res.Count == inv.arg.Limit {
+		inv.res.Bookmark = meta.Bookmark
+	}
+
+	return nil
+}
+
+func (inv *queryShipmentsInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -5,50 +5,82 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	registerShipmentCoSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:registerShipmentCoSchema",
+	"type": "object",
+	"properties": {
+		"name": {
+			"type": "string",
+			"minLength": 3,
+			"maxLength": 100
+		},
+		"allowDuplicate": {
+			"type": "boolean",
+			"description": "admins only, register even if the participant seems to be registered already"
+		},
+		"address": %s
+	},
+	"required": [ "name", "address" ]
+}
+`, addressSchema)
+	registerShipmentCoSchemaLoader = gojsonschema.NewStringLoader(registerShipmentCoSchema)
 )
 
 // Creates a new Participant, by name and address. Returns the Id
 type registerShipmentCoArg struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
+	Name           string  `json:"name"`
+	Address        Address `json:"address"`
+	AllowDuplicate bool    `json:"allowDuplicate"`
 }
 
 // Returns ID of shipment
 type registerShipmentCoResult struct {
-	ID string `json:"id"`
+	ID         string   `json:"id"`
+	Duplicates []string `json:"duplicates,omitempty"` // if registered anyway
 }
 
 type registerShipmentCoInvocation struct {
+	eventRecorder
+
 	arg registerShipmentCoArg
 	res registerShipmentCoResult
 }
 
 func (inv *registerShipmentCoInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter registerShipmentCoInvocation.checkParseArguments")
-
-	_, args := stub.GetFunctionAndParameters()
+	loggerFor(stub).Debug("enter registerShipmentCoInvocation.checkParseArguments")
 
-	if len(args) != 1 {
-		return errors.New("Expecting JSON input as first param")
+	inv.arg = registerShipmentCoArg{}
+	if err := parseArgument(stub, registerShipmentCoSchemaLoader, &inv.arg); err != nil {
+		return err
 	}
 
-	inv.arg = registerShipmentCoArg{}
-	err := json.Unmarshal([]byte(args[0]), &inv.arg)
-	if err != nil {
-		logger.Printf("Error unmarshaling JSON: %s", err)
-		return errors.New("Invalid JSON")
+	// only admins may register duplicates
+	if inv.arg.AllowDuplicate && !callerIsAdmin(stub) {
+		return errNotAuthorized
 	}
 	return nil
 }
 
 func (inv *registerShipmentCoInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter registerShipmentCo.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter registerShipmentCo.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
 
-	idStr, err := newID(stub, "ShipmentCo")
+	identity, err := callerID(stub)
 	if err != nil {
-		logger.Println(err)
+		return err
+	}
+
+	idStr, err := newParticipantID(stub)
+	if err != nil {
+		loggerFor(stub).Error(err)
 		return errors.New("internal error generating index key")
 	}
 
@@ -57,32 +89,45 @@ func (inv *registerShipmentCoInvocation) process(stub shim.ChaincodeStubInterfac
 			ID: ID{
 				ID: idStr,
 			},
-			Name: inv.arg.Name,
+			Name:     inv.arg.Name,
+			Identity: identity,
+			Status:   ParticipantActive,
 		},
 		Address: inv.arg.Address,
 	}
 
-	ck, err := getShipmentCoKey(stub, idStr)
+	duplicates, err := checkDuplicateParticipants(stub, KindShipmentCo, &p, inv.arg.AllowDuplicate)
 	if err != nil {
-		logger.Println(err)
+		return err
+	}
+
+	ck, err := shipmentCoRegistry().key(stub, idStr)
+	if err != nil {
+		loggerFor(stub).Error(err)
 		return errors.New("internal error generating composite key (2)")
 	}
-	logger.Printf("key=%s\n", ck)
+	loggerFor(stub).Debugf("key=%s", ck)
 
 	data, err := json.Marshal(p)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal JSON marshal error (1)")
 	}
 	err = stub.PutState(ck, []byte(data))
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error writing world state")
 	}
-	logger.Printf("PutState to key=%s, data=%#v\n", ck, p)
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, p)
+
+	if err = indexParticipant(stub, KindShipmentCo, &p); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: KindShipmentCo, Participant: p})
 
 	inv.res = registerShipmentCoResult{
-		ID: idStr,
+		ID:         idStr,
+		Duplicates: duplicates,
 	}
 
 	return nil
@@ -0,0 +1,89 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	registerContainerSchema = `
+{
+	"$id": "PreciousCargoShippping:registerContainerSchema",
+	"type": "object",
+	"properties": {
+		"owner": {
+			"type": "string",
+			"description": "ID of ShipmentCo owning the container",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"type": {
+			"type": "string",
+			"description": "type of container, e.g. 20ft, 40ft, ULD",
+			"minLength": 1,
+			"maxLength": 100
+		}
+	},
+	"required": [ "owner", "type" ]
+}
+`
+	registerContainerSchemaLoader = gojsonschema.NewStringLoader(registerContainerSchema)
+)
+
+// Registers a container of a ShipmentCo. Returns the Id
+type registerContainerArg struct {
+	Owner string `json:"owner"`
+	Type  string `json:"type"`
+}
+
+// Returns ID of container
+type registerContainerResult struct {
+	ID string `json:"id"`
+}
+
+type registerContainerInvocation struct {
+	arg registerContainerArg
+	res registerContainerResult
+}
+
+func (inv *registerContainerInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerContainerInvocation.checkParseArguments")
+
+	inv.arg = registerContainerArg{}
+	err := parseArgument(stub, registerContainerSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	_, x, err := shipmentCoRegistry().get(stub, inv.arg.Owner)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("invalid owner argument: Not found")
+	}
+	return checkCallerIs(stub, x.(*ShipmentCo).Participant)
+}
+
+func (inv *registerContainerInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerContainerInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	id, err := containerRegistry().create(stub, &Container{
+		Type:    inv.arg.Type,
+		OwnerID: inv.arg.Owner,
+	})
+	if err != nil {
+		return err
+	}
+	inv.res = registerContainerResult{
+		ID: id,
+	}
+
+	return nil
+}
+
+func (inv *registerContainerInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,140 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	registerCorporateParticipantSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:registerCorporateParticipantSchema",
+	"type": "object",
+	"properties": {
+		"name": {
+			"type": "string",
+			"minLength": 3,
+			"maxLength": 100
+		},
+		"address": %s,
+		"allowDuplicate": {
+			"type": "boolean",
+			"description": "admins only, register even if the participant seems to be registered already"
+		},
+		"registrationNumber": {
+			"type": "string",
+			"description": "number in company register",
+			"minLength": 1,
+			"maxLength": 64
+		},
+		"vatId": {
+			"type": "string",
+			"description": "VAT identification number, starting with country code",
+			"pattern": "^[A-Z]{2}[0-9A-Z+*.]{2,13}$"
+		},
+		"contacts": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"name": { "type": "string", "minLength": 1, "maxLength": 100 },
+					"email": { "type": "string", "format": "email" },
+					"phone": { "type": "string", "pattern": "^\\+?[0-9 ()/-]{4,32}$" },
+					"role": { "type": "string", "maxLength": 100 }
+				},
+				"required": [ "name" ]
+			}
+		}
+	},
+	"required": [ "name", "address", "registrationNumber" ]
+}
+`, addressSchema)
+	registerCorporateParticipantSchemaLoader = gojsonschema.NewStringLoader(registerCorporateParticipantSchema)
+)
+
+// Creates a new company as sender or recipient of shipments. Returns the Id
+type registerCorporateParticipantArg struct {
+	Name               string          `json:"name"`
+	Address            Address         `json:"address"`
+	RegistrationNumber string          `json:"registrationNumber"`
+	VATID              string          `json:"vatId"`
+	Contacts           []ContactPerson `json:"contacts"`
+	AllowDuplicate     bool            `json:"allowDuplicate"`
+}
+
+// Returns ID of participant
+type registerCorporateParticipantResult struct {
+	ID         string   `json:"id"`
+	Duplicates []string `json:"duplicates,omitempty"` // if registered anyway
+}
+
+type registerCorporateParticipantInvocation struct {
+	eventRecorder
+
+	arg registerCorporateParticipantArg
+	res registerCorporateParticipantResult
+}
+
+func (inv *registerCorporateParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerCorporateParticipantInvocation.checkParseArguments")
+
+	inv.arg = registerCorporateParticipantArg{}
+	if err := parseArgument(stub, registerCorporateParticipantSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+
+	// only admins may register duplicates
+	if inv.arg.AllowDuplicate && !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+	return nil
+}
+
+func (inv *registerCorporateParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerCorporateParticipantInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	identity, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+
+	p := &CorporateParticipant{
+		Participant: Participant{
+			Name:     inv.arg.Name,
+			Identity: identity,
+			Status:   ParticipantActive,
+		},
+		Address:            inv.arg.Address,
+		RegistrationNumber: inv.arg.RegistrationNumber,
+		VATID:              inv.arg.VATID,
+		Contacts:           inv.arg.Contacts,
+	}
+	duplicates, err := checkDuplicateParticipants(stub, KindCorporateParticipant, p, inv.arg.AllowDuplicate)
+	if err != nil {
+		return err
+	}
+
+	id, err := corporateParticipantRegistry().create(stub, p)
+	if err != nil {
+		return err
+	}
+	if err = indexParticipant(stub, KindCorporateParticipant, p); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: KindCorporateParticipant, Participant: p})
+	inv.res = registerCorporateParticipantResult{
+		ID:         id,
+		Duplicates: duplicates,
+	}
+
+	return nil
+}
+
+func (inv *registerCorporateParticipantInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,131 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	registerDeviceSchema = `
+{
+	"$id": "PreciousCargoShippping:registerDeviceSchema",
+	"type": "object",
+	"properties": {
+		"owner": {
+			"type": "string",
+			"description": "ID of ShipmentCo owning the device",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"publicKey": {
+			"type": "string",
+			"description": "PEM encoded PKIX public key (ECDSA or Ed25519) of the device",
+			"minLength": 1
+		},
+		"model": {
+			"type": "string",
+			"minLength": 1,
+			"maxLength": 100
+		},
+		"calibrationDate": {
+			"type": "string",
+			"description": "time of last calibration in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		}
+	},
+	"required": [ "owner", "publicKey", "model", "calibrationDate" ]
+}
+`
+	registerDeviceSchemaLoader = gojsonschema.NewStringLoader(registerDeviceSchema)
+)
+
+// Registers a tracking device for a ShipmentCo. Returns the Id
+type registerDeviceArg struct {
+	Owner           string `json:"owner"`
+	PublicKey       string `json:"publicKey"`
+	Model           string `json:"model"`
+	CalibrationDate string `json:"calibrationDate"`
+}
+
+// Returns ID of device
+type registerDeviceResult struct {
+	ID string `json:"id"`
+}
+
+type registerDeviceInvocation struct {
+	arg registerDeviceArg
+
+	calibratedAt time.Time
+
+	res registerDeviceResult
+}
+
+func (inv *registerDeviceInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerDeviceInvocation.checkParseArguments")
+
+	inv.arg = registerDeviceArg{}
+	err := parseArgument(stub, registerDeviceSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.calibratedAt, err = time.Parse(time.RFC3339, inv.arg.CalibrationDate)
+	if err != nil {
+		return errors.New("invalid calibrationDate argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
+	}
+
+	if _, err = parseDevicePublicKey(inv.arg.PublicKey); err != nil {
+		return fmt.Errorf("invalid publicKey argument: %s", err)
+	}
+
+	_, x, err := shipmentCoRegistry().get(stub, inv.arg.Owner)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("invalid owner argument: Not found")
+	}
+
+	// only the owner registers its devices
+	if err = checkCallerIs(stub, x.(*ShipmentCo).Participant); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (inv *registerDeviceInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter registerDeviceInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	if inv.calibratedAt.After(now) {
+		return errors.New("invalid calibrationDate argument: Must not be in the future")
+	}
+
+	id, err := deviceRegistry().create(stub, &Device{
+		OwnerID:      inv.arg.Owner,
+		PublicKey:    inv.arg.PublicKey,
+		Model:        inv.arg.Model,
+		CalibratedAt: inv.calibratedAt,
+		Status:       DeviceActive,
+		RegisteredAt: now,
+	})
+	if err != nil {
+		return err
+	}
+	inv.res = registerDeviceResult{
+		ID: id,
+	}
+
+	return nil
+}
+
+func (inv *registerDeviceInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -5,11 +5,39 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	registerIndividualParticipantSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:registerIndividualParticipantSchema",
+	"type": "object",
+	"properties": {
+		"name": {
+			"type": "string",
+			"minLength": 3,
+			"maxLength": 100
+		},
+		"allowDuplicate": {
+			"type": "boolean",
+			"description": "admins only, register even if the participant seems to be registered already"
+		},
+		"address": %s
+	},
+	"required": [ "name", "address" ]
+}
+`, addressSchema)
+	registerIndividualParticipantSchemaLoader = gojsonschema.NewStringLoader(registerIndividualParticipantSchema)
 )
 
 // Invocation struct to register an IndividualParticipant
 type registerIndividualParticipantInvocation struct {
+	eventRecorder
+
 	// input arguments (from client)
 	arg registerIndividualParticipantArg
 
@@ -22,30 +50,24 @@ type registerIndividualParticipantInvocation struct {
 
 // Creates a new Participant, by name and address. Returns the Id
 type registerIndividualParticipantArg struct {
-	Name    string `json:"name"`
-	Address string `json:"address"`
+	Name           string  `json:"name" pii:"true"`
+	Address        Address `json:"address" pii:"true"`
+	AllowDuplicate bool    `json:"allowDuplicate"`
 }
 
 // Returns ID of shipment
 type registerIndividualParticipantResult struct {
-	ID string `json:"id"`
+	ID         string   `json:"id"`
+	Duplicates []string `json:"duplicates,omitempty"` // if registered anyway
 }
 
 // Unmarshal input argument, optionally check them
 func (inv *registerIndividualParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter registerIndividualParticipantInvocation.checkParseArguments")
-
-	_, args := stub.GetFunctionAndParameters()
-
-	if len(args) != 1 {
-		return errors.New("Expecting JSON input as first param")
-	}
+	loggerFor(stub).Debug("enter registerIndividualParticipantInvocation.checkParseArguments")
 
 	inv.arg = registerIndividualParticipantArg{}
-	err := json.Unmarshal([]byte(args[0]), &inv.arg)
-	if err != nil {
-		logger.Printf("Error unmarshaling JSON: %s", err)
-		return errors.New("Invalid JSON")
+	if err := parseArgument(stub, registerIndividualParticipantSchemaLoader, &inv.arg); err != nil {
+		return err
 	}
 
 	// programmatic input check
@@ -56,18 +78,30 @@ func (inv *registerIndividualParticipantInvocation) checkParseArguments(stub shi
 		return errors.New("Invalid input, name is too long")
 	}
 
+	// only admins may register duplicates
+	if inv.arg.AllowDuplicate && !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+
 	return nil
 }
 
 // Processes the invocation. Updates the invocation struct. Returns an error or nil if successful.
 func (inv *registerIndividualParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter registerIndividualParticipant.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter registerIndividualParticipant.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	// remember who registered, so that the participant can be managed
+	// by this identity only
+	identity, err := callerID(stub)
+	if err != nil {
+		return err
+	}
 
-	// Create an ID for the new participant
-	s, err := newID(stub, "IndividualParticipant")
+	// Create an ID for the new participant, unique among all kinds of participants
+	s, err := newParticipantID(stub)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error generating index key")
 	}
 	inv.idStr = s
@@ -78,36 +112,50 @@ func (inv *registerIndividualParticipantInvocation) process(stub shim.ChaincodeS
 			ID: ID{
 				ID: inv.idStr,
 			},
-			Name: inv.arg.Name,
+			Name:     inv.arg.Name,
+			Identity: identity,
+			Status:   ParticipantActive,
 		},
 		Address: inv.arg.Address,
 	}
 
+	// refuse to register the same person twice
+	duplicates, err := checkDuplicateParticipants(stub, KindIndividualParticipant, &p, inv.arg.AllowDuplicate)
+	if err != nil {
+		return err
+	}
+
 	// combine namespace, type and ID into a key
 	ck, err := stub.CreateCompositeKey(ns, []string{".", "IndividualParticipant", "#", inv.idStr})
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error generating composite key (2)")
 	}
-	logger.Printf("key=%s\n", ck)
+	loggerFor(stub).Debugf("key=%s", ck)
 
 	// marshal data to json and ...
 	data, err := json.Marshal(p)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal JSON marshal error (1)")
 	}
 	// ... save to world state
 	err = stub.PutState(ck, []byte(data))
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return errors.New("internal error writing world state")
 	}
-	logger.Printf("PutState to key=%s, data=%#v\n", ck, p)
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, p)
+
+	if err = indexParticipant(stub, KindIndividualParticipant, &p); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: KindIndividualParticipant, Participant: p})
 
 	// return struct to client contains ID
 	inv.res = registerIndividualParticipantResult{
-		ID: inv.idStr,
+		ID:         inv.idStr,
+		Duplicates: duplicates,
 	}
 
 	return nil
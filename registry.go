@@ -18,24 +18,40 @@ type registryInterface interface {
 	key(stub shim.ChaincodeStubInterface, id string) (string, error)
 
 	// creates a new data item by marshaling into JSON. Returns
-	// ID of newly created item.
+	// ID of newly created item. If item is a pointer to a struct
+	// embedding ID, its ID is set as well.
 	create(stub shim.ChaincodeStubInterface, item interface{}) (string, error)
 
 	// get retrieves an item by its ID
 	get(stub shim.ChaincodeStubInterface, id string) (string, interface{}, error)
+
+	// update overwrites an existing data item by its ID
+	update(stub shim.ChaincodeStubInterface, id string, item interface{}) error
+}
+
+// identifiable is implemented by data items embedding ID, so that
+// a registry can set the ID of newly created items.
+type identifiable interface {
+	setID(id string)
+}
+
+func (i *ID) setID(id string) {
+	i.ID = id
 }
 
 // registry is a concrete registry with a type, given by its name (for creating keys)
-// and its reflect.Type (for creating structs dynamically)
+// and its reflect.Type (for creating structs dynamically). IDs are generated
+// per type, unless idIndex names an index shared with other types.
 type registry struct {
 	typeStr string
 	typeRT  reflect.Type
+	idIndex string
 }
 
 func (r registry) key(stub shim.ChaincodeStubInterface, id string) (string, error) {
 	ck, err := stub.CreateCompositeKey(ns, []string{".", r.typeStr, "#", id})
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Warn(err)
 		return "", err
 	}
 	return ck, nil
@@ -43,31 +59,41 @@ func (r registry) key(stub shim.ChaincodeStubInterface, id string) (string, erro
 }
 
 func (r registry) create(stub shim.ChaincodeStubInterface, item interface{}) (string, error) {
-	idStr, err := newID(stub, r.typeStr)
+	var idStr string
+	var err error
+	if r.idIndex == participantIndex {
+		idStr, err = newParticipantID(stub)
+	} else {
+		idStr, err = newID(stub, r.typeStr)
+	}
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", errors.New("internal error generating index key")
 	}
-	ck, err := getShipmentKey(stub, idStr)
+	ck, err := r.key(stub, idStr)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", errors.New("internal error generating composite key")
 	}
-	logger.Printf("key=%s\n", ck)
+	loggerFor(stub).Debugf("key=%s", ck)
+
+	if i, ok := item.(identifiable); ok {
+		i.setID(idStr)
+	}
 
 	data, err := json.Marshal(&item)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", errors.New("internal JSON marshal error")
 	}
 	err = stub.PutState(ck, []byte(data))
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", errors.New("internal error writing world state")
 	}
-	logger.Printf("PutState to key=%s, data=%#v\n", ck, item)
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, item)
 
-	return ck, nil
+	return idStr, nil
 }
 
 func (r registry) get(stub shim.ChaincodeStubInterface, id string) (string, interface{}, error) {
@@ -77,17 +103,44 @@ func (r registry) get(stub shim.ChaincodeStubInterface, id string) (string, inte
 	}
 	data, err := stub.GetState(ck)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", nil, errors.New("internal error reading from world state (1)")
 	}
+	if data == nil {
+		loggerFor(stub).Debugf("Nothing found for key=%s", ck)
+		return "", nil, errNotFound
+	}
+	// typeRT is a pointer type, so res.Interface() is a pointer to
+	// a pointer which json.Unmarshal will allocate.
 	res := reflect.New(r.typeRT)
-	err = json.Unmarshal(data, &res)
+	err = json.Unmarshal(data, res.Interface())
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Error(err)
 		return "", nil, errors.New("internal error reading from world state (2)")
 	}
-	logger.Printf("Found value=%#v for key=%s\n", res, id)
+	loggerFor(stub).Debugf("Found value=%v for key=%s", res.Elem().Interface(), id)
+
+	return ck, res.Elem().Interface(), nil
 
-	return ck, res, nil
+}
+
+func (r registry) update(stub shim.ChaincodeStubInterface, id string, item interface{}) error {
+	ck, err := r.key(stub, id)
+	if err != nil {
+		return errors.New("internal error generating composite key")
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal JSON marshal error")
+	}
+	err = stub.PutState(ck, data)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error writing world state")
+	}
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, item)
 
+	return nil
 }
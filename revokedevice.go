@@ -0,0 +1,97 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	revokeDeviceSchema = `
+{
+	"$id": "PreciousCargoShippping:revokeDeviceSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Device",
+			"pattern": "^([0-9]{4,32})$"
+		}
+	},
+	"required": [ "id" ]
+}
+`
+	revokeDeviceSchemaLoader = gojsonschema.NewStringLoader(revokeDeviceSchema)
+)
+
+// Revokes a device by Id. Revoked devices can not deliver tracking data.
+type revokeDeviceArg struct {
+	ID string `json:"id"`
+}
+
+type revokeDeviceInvocation struct {
+	arg revokeDeviceArg
+
+	device *Device
+
+	res Device
+}
+
+func (inv *revokeDeviceInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter revokeDeviceInvocation.checkParseArguments")
+
+	inv.arg = revokeDeviceArg{}
+	err := parseArgument(stub, revokeDeviceSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	_, x, err := deviceRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate device for this ID")
+	}
+	inv.device = x.(*Device)
+	if inv.device.Status == DeviceRevoked {
+		return errors.New("device is already revoked")
+	}
+
+	// only the owner revokes its devices
+	_, x, err = shipmentCoRegistry().get(stub, inv.device.OwnerID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate owner of device")
+	}
+	if err = checkCallerIs(stub, x.(*ShipmentCo).Participant); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (inv *revokeDeviceInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter revokeDeviceInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	inv.device.Status = DeviceRevoked
+	inv.device.RevokedAt = &now
+
+	err = deviceRegistry().update(stub, inv.device.ID.ID, inv.device)
+	if err != nil {
+		return err
+	}
+	inv.res = *inv.device
+
+	return nil
+}
+
+func (inv *revokeDeviceInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
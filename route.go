@@ -0,0 +1,219 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Geofence events are stored under
+//
+//	ns . GeofenceEvent # <shipment id> <day> <time of day> <device id> <type> <geofence>
+//
+// with day and time as for tracking data points, so that a partial key
+// scan returns them in order of measurement time.
+const geofenceEventType = "GeofenceEvent"
+
+// validate checks a route given by a client. Origin and destination
+// are named "origin" and "destination" if not given.
+func (r *PlannedRoute) validate() error {
+	if r.Origin != nil && r.Origin.Name == "" {
+		r.Origin.Name = "origin"
+	}
+	if r.Destination != nil && r.Destination.Name == "" {
+		r.Destination.Name = "destination"
+	}
+
+	names := map[string]bool{}
+	for _, g := range r.geofences() {
+		if g.Name == "" {
+			return errors.New("geofence without name")
+		}
+		if names[g.Name] {
+			return fmt.Errorf("duplicate geofence name %s", g.Name)
+		}
+		names[g.Name] = true
+		if len(g.Polygon) < 3 {
+			return fmt.Errorf("geofence %s: Polygon needs at least 3 points", g.Name)
+		}
+		for _, p := range g.Polygon {
+			if !p.valid() {
+				return fmt.Errorf("geofence %s: Invalid coordinates", g.Name)
+			}
+		}
+	}
+
+	if len(r.Corridor) > 0 {
+		if len(r.Corridor) < 2 {
+			return errors.New("corridor needs at least 2 points")
+		}
+		for _, p := range r.Corridor {
+			if !p.valid() {
+				return errors.New("corridor: Invalid coordinates")
+			}
+		}
+		if r.CorridorWidthKm <= 0 {
+			return errors.New("corridorWidthKm must be positive")
+		}
+	}
+	return nil
+}
+
+// geofences returns origin, waypoints and destination
+func (r *PlannedRoute) geofences() []Geofence {
+	res := []Geofence{}
+	if r.Origin != nil {
+		res = append(res, *r.Origin)
+	}
+	res = append(res, r.Waypoints...)
+	if r.Destination != nil {
+		res = append(res, *r.Destination)
+	}
+	return res
+}
+
+// offRoute checks whether a position is outside of the corridor
+func (r *PlannedRoute) offRoute(p GeoPoint) bool {
+	if len(r.Corridor) == 0 {
+		return false
+	}
+	return distanceToPolylineKm(p, r.Corridor) > r.CorridorWidthKm/2
+}
+
+// evaluateRoute checks data points written in this transaction against the
+// planned route of their shipment, updates the route state of the shipment
+// and records geofence events. The shipment is not saved. Returns the events.
+//
+// Entering and leaving geofences is evaluated from points newer than the
+// route state only. An out-of-order point can not change what happened
+// afterwards, but is still reported if it is off route.
+func evaluateRoute(stub shim.ChaincodeStubInterface, shipment *Shipment, points []TrackingDataPoint) ([]GeofenceEvent, error) {
+	r := shipment.Route
+	if r == nil || len(points) == 0 {
+		return nil, nil
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		return points[i].At.Before(points[j].At)
+	})
+
+	state := shipment.RouteState
+	if state == nil {
+		state = &RouteState{Inside: []string{}}
+		shipment.RouteState = state
+	}
+
+	events := []GeofenceEvent{}
+	newEvent := func(tdp TrackingDataPoint, typ, geofence string) {
+		events = append(events, GeofenceEvent{
+			ShipmentID: shipment.ID.ID,
+			Type:       typ,
+			Geofence:   geofence,
+			At:         tdp.At,
+			Position:   tdp.position(),
+			DeviceID:   tdp.DeviceID,
+		})
+	}
+
+	for _, tdp := range points {
+		pos := tdp.position()
+		offRoute := r.offRoute(pos)
+
+		if tdp.At.Before(state.EvaluatedAt) {
+			if offRoute {
+				newEvent(tdp, RouteOffRoute, "")
+			}
+			continue
+		}
+
+		wasInside := map[string]bool{}
+		for _, name := range state.Inside {
+			wasInside[name] = true
+		}
+		inside := []string{}
+		for _, g := range r.geofences() {
+			in := insidePolygon(pos, g.Polygon)
+			if in {
+				inside = append(inside, g.Name)
+			}
+			if in && !wasInside[g.Name] {
+				newEvent(tdp, GeofenceEnter, g.Name)
+			}
+			if !in && wasInside[g.Name] {
+				newEvent(tdp, GeofenceExit, g.Name)
+			}
+		}
+		if offRoute && !state.OffRoute {
+			newEvent(tdp, RouteOffRoute, "")
+		}
+		if !offRoute && state.OffRoute {
+			newEvent(tdp, RouteBackOnRoute, "")
+		}
+
+		state.Inside = inside
+		state.OffRoute = offRoute
+		state.EvaluatedAt = tdp.At
+	}
+
+	for _, ev := range events {
+		if err := putGeofenceEvent(stub, ev); err != nil {
+			return nil, err
+		}
+	}
+	return events, nil
+}
+
+func putGeofenceEvent(stub shim.ChaincodeStubInterface, ev GeofenceEvent) error {
+	attrs := append([]string{".", geofenceEventType, "#", ev.ShipmentID}, sortableTimeAttrs(ev.At)...)
+	ck, err := stub.CreateCompositeKey(ns, append(attrs, ev.DeviceID, ev.Type, ev.Geofence))
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error generating composite key")
+	}
+	data, err := json.Marshal(ev)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal JSON marshal error")
+	}
+	err = stub.PutState(ck, data)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error writing world state")
+	}
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, ev)
+
+	return nil
+}
+
+// scanGeofenceEvents calls fn for all geofence events of a shipment, in
+// order of measurement time.
+func scanGeofenceEvents(stub shim.ChaincodeStubInterface, shipmentID string, fn func(GeofenceEvent) error) error {
+	it, err := stub.GetStateByPartialCompositeKey(ns, []string{".", geofenceEventType, "#", shipmentID})
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error reading from world state")
+	}
+	defer it.Close()
+
+	for it.HasNext() {
+		kv, err := it.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state")
+		}
+		var ev GeofenceEvent
+		err = json.Unmarshal(kv.Value, &ev)
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state (2)")
+		}
+		if err = fn(ev); err != nil {
+			return err
+		}
+	}
+	return nil
+}
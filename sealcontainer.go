@@ -0,0 +1,130 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	sealContainerSchema = `
+{
+	"$id": "PreciousCargoShippping:sealContainerSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Container"
+		},
+		"seals": {
+			"type": "array",
+			"description": "numbers of the seals applied",
+			"minItems": 1,
+			"items": { "type": "string", "minLength": 1, "maxLength": 64 }
+		},
+		"remarks": {
+			"type": "string",
+			"maxLength": 500
+		}
+	},
+	"required": [ "id", "seals" ]
+}
+`
+	sealContainerSchemaLoader = gojsonschema.NewStringLoader(sealContainerSchema)
+)
+
+// Applies seals to a container
+type sealContainerArg struct {
+	ID      string   `json:"id"`
+	Seals   []string `json:"seals"`
+	Remarks string   `json:"remarks"`
+}
+
+type sealContainerInvocation struct {
+	eventRecorder
+
+	arg sealContainerArg
+
+	container *Container
+
+	// returns the updated container
+	res Container
+}
+
+func (inv *sealContainerInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter sealContainerInvocation.checkParseArguments")
+
+	inv.arg = sealContainerArg{}
+	err := parseArgument(stub, sealContainerSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.container, err = getContainer(stub, inv.arg.ID)
+	if err != nil {
+		return err
+	}
+	if err = checkCallerHandlesContainer(stub, inv.container); err != nil {
+		return err
+	}
+
+	// seal numbers are unique per container
+	seen := map[string]bool{}
+	for _, number := range inv.arg.Seals {
+		if _, err = inv.container.seal(number); err == nil || seen[number] {
+			return fmt.Errorf("invalid seals argument: Seal %s used already", number)
+		}
+		seen[number] = true
+	}
+	return nil
+}
+
+func (inv *sealContainerInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter sealContainerInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	by, err := callerID(stub)
+	if err != nil {
+		return err
+	}
+
+	for _, number := range inv.arg.Seals {
+		inv.container.Seals = append(inv.container.Seals, Seal{
+			Number:   number,
+			Status:   SealIntact,
+			SealedAt: now,
+		})
+		sc := SealCheck{
+			ContainerID: inv.container.ID.ID,
+			SealNumber:  number,
+			Action:      SealActionSealed,
+			Intact:      true,
+			By:          by,
+			At:          now,
+			Remarks:     inv.arg.Remarks,
+		}
+		if err = putSealCheck(stub, sc); err != nil {
+			return err
+		}
+		inv.emit(sealCheckType, sc)
+	}
+
+	if err = containerRegistry().update(stub, inv.container.ID.ID, inv.container); err != nil {
+		return errors.New("internal error writing world state")
+	}
+	inv.res = *inv.container
+
+	return nil
+}
+
+func (inv *sealContainerInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,184 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Shipments are indexed by participants and status, with keys
+// [".", shipmentIndex, "#", field, value, shipmentID]. Index entries are
+// written together with the shipment by saveShipment, so all shipment
+// writes must go through it. Shipments written before the index existed
+// are indexed when they are saved next.
+const shipmentIndex = "ShipmentBy"
+
+// docType of shipments, so that rich queries can tell them from other
+// JSON documents in the world state.
+const shipmentDocType = "Shipment"
+
+// indexed shipment fields
+const (
+	shipmentByShipper = "shipper"
+	shipmentByFrom    = "from"
+	shipmentByTo      = "to"
+	shipmentByCarrier = "carrier"
+	shipmentByStatus  = "status"
+)
+
+// indexValues returns the values a shipment is indexed by, per field.
+// A shipment is indexed by all carriers of its legs.
+func (s *Shipment) indexValues() map[string][]string {
+	carriers := []string{}
+	seen := map[string]bool{}
+	for _, leg := range s.Legs {
+		if !seen[leg.CarrierID] {
+			seen[leg.CarrierID] = true
+			carriers = append(carriers, leg.CarrierID)
+		}
+	}
+	return map[string][]string{
+		shipmentByShipper: {s.ShipperID},
+		shipmentByFrom:    {s.FromID},
+		shipmentByTo:      {s.ToID},
+		shipmentByCarrier: carriers,
+		shipmentByStatus:  {s.Status},
+	}
+}
+
+// matches checks a shipment against a value per field
+func (s *Shipment) matches(filters map[string]string) bool {
+	values := s.indexValues()
+	for field, value := range filters {
+		found := false
+		for _, v := range values[field] {
+			if v == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+func shipmentIndexKeys(stub shim.ChaincodeStubInterface, s *Shipment) (map[string]bool, error) {
+	res := map[string]bool{}
+	for field, values := range s.indexValues() {
+		for _, value := range values {
+			if value == "" {
+				continue
+			}
+			ck, err := stub.CreateCompositeKey(ns, []string{".", shipmentIndex, "#", field, value, s.ID.ID})
+			if err != nil {
+				loggerFor(stub).Error(err)
+				return nil, errors.New("internal error generating composite key")
+			}
+			res[ck] = true
+		}
+	}
+	return res, nil
+}
+
+// Emitted when a shipment has been created or updated, for off-chain
+// copies of the shipments. Tracking data is sent separately.
+type shipmentSavedEvent struct {
+	Shipment Shipment `json:"shipment"`
+}
+
+// saveShipment creates a shipment if it has no ID yet, or saves it under
+// its ID otherwise, which may have been taken with newIDs. Updates the
+// index entries of the shipment in the same transaction. Also sets the
+// docType. Returns the ID. If ev is given, a ShipmentSaved event is
+// emitted.
+func saveShipment(stub shim.ChaincodeStubInterface, ev *eventRecorder, s *Shipment) (string, error) {
+	s.DocType = shipmentDocType
+
+	old := map[string]bool{}
+	if s.ID.ID == "" {
+		if _, err := shipmentRegistry().create(stub, s); err != nil {
+			return "", err
+		}
+	} else {
+		_, x, err := shipmentRegistry().get(stub, s.ID.ID)
+		if err != nil && err != errNotFound {
+			return "", err
+		}
+		if err == nil {
+			if old, err = shipmentIndexKeys(stub, x.(*Shipment)); err != nil {
+				return "", err
+			}
+		}
+		if err = shipmentRegistry().update(stub, s.ID.ID, s); err != nil {
+			return "", err
+		}
+	}
+
+	keys, err := shipmentIndexKeys(stub, s)
+	if err != nil {
+		return "", err
+	}
+	for ck := range old {
+		if keys[ck] {
+			continue
+		}
+		if err = stub.DelState(ck); err != nil {
+			loggerFor(stub).Error(err)
+			return "", errors.New("internal error writing world state")
+		}
+	}
+	for ck := range keys {
+		if old[ck] {
+			continue
+		}
+		if err = stub.PutState(ck, []byte(s.ID.ID)); err != nil {
+			loggerFor(stub).Error(err)
+			return "", errors.New("internal error writing world state")
+		}
+	}
+
+	if ev != nil {
+		// a copy, later changes in this invocation are saved again
+		snapshot := *s
+		snapshot.Summary, snapshot.RouteState = nil, nil
+		ev.emit("ShipmentSaved", shipmentSavedEvent{Shipment: snapshot})
+	}
+
+	return s.ID.ID, nil
+}
+
+// scanShipmentIndex calls fn with the IDs of shipments indexed by a field
+// value, in ascending order, starting after the given ID. Stops if fn
+// returns false.
+func scanShipmentIndex(stub shim.ChaincodeStubInterface, field, value, after string, fn func(id string) (bool, error)) error {
+	iter, err := stub.GetStateByPartialCompositeKey(ns, []string{".", shipmentIndex, "#", field, value})
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error reading from world state")
+	}
+	defer iter.Close()
+
+	for iter.HasNext() {
+		kv, err := iter.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state")
+		}
+		id := string(kv.Value)
+		if id <= after {
+			continue
+		}
+		more, err := fn(id)
+		if err != nil {
+			return err
+		}
+		if !more {
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"time"
+)
+
+// parseDevicePublicKey decodes a PEM encoded PKIX public key. Devices
+// may use ECDSA or Ed25519 keys.
+func parseDevicePublicKey(pemStr string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		logger.Warn(err)
+		return nil, errors.New("unable to parse PKIX public key")
+	}
+	switch pub.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return pub, nil
+	}
+	return nil, errors.New("unsupported key type, must be ECDSA or Ed25519")
+}
+
+// signedPayloadVersion is the version of the payload trackers sign
+const signedPayloadVersion = 1
+
+// signedPayloadV1 is what a tracker signs, as compact JSON with the
+// fields in this order and no whitespace, e.g.
+//
+//	{"v":1,"shipmentId":"0000000001","device":"0000000001","counter":7,
+//	 "at":"2019-05-01T12:30:00Z","lat":52.5,"lng":13.4,"temp":4,"hum":40}
+//
+// at is RFC 3339 in UTC, with fractional seconds only if there are any.
+// Numbers are written as short as possible, temp and hum with single
+// precision. It is independent of how data points are stored, and must
+// not change: devices in the field sign it. A new version gets a new
+// struct.
+type signedPayloadV1 struct {
+	Version     int     `json:"v"`
+	ShipmentID  string  `json:"shipmentId"`
+	DeviceID    string  `json:"device"`
+	Counter     uint64  `json:"counter"`
+	At          string  `json:"at"`
+	Latitude    float64 `json:"lat"`
+	Longitude   float64 `json:"lng"`
+	Temperature float32 `json:"temp"`
+	Humidity    float32 `json:"hum"`
+}
+
+// signedPayload returns the payload a tracker signs for a data point
+func (tdp TrackingDataPoint) signedPayload() ([]byte, error) {
+	return json.Marshal(signedPayloadV1{
+		Version:     signedPayloadVersion,
+		ShipmentID:  tdp.ShipmentID.ID,
+		DeviceID:    tdp.DeviceID,
+		Counter:     tdp.Counter,
+		At:          tdp.At.UTC().Format(time.RFC3339Nano),
+		Latitude:    tdp.Latitude,
+		Longitude:   tdp.Longitude,
+		Temperature: tdp.Temperature,
+		Humidity:    tdp.Humidity,
+	})
+}
+
+// verifyTrackingDataPoint checks the signature of a data point
+// against the public key of the device that produced it. ECDSA
+// signatures are ASN.1 encoded over the SHA-256 digest of the payload,
+// Ed25519 signatures are over the payload itself. Signatures
+// are transported in base64.
+func verifyTrackingDataPoint(device *Device, tdp TrackingDataPoint) error {
+	pub, err := parseDevicePublicKey(device.PublicKey)
+	if err != nil {
+		return err
+	}
+	sig, err := base64.StdEncoding.DecodeString(tdp.Signature)
+	if err != nil {
+		return errors.New("signature is not valid base64")
+	}
+	payload, err := tdp.signedPayload()
+	if err != nil {
+		logger.Error(err)
+		return errors.New("internal JSON marshal error")
+	}
+
+	valid := false
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		digest := sha256.Sum256(payload)
+		valid = ecdsa.VerifyASN1(k, digest[:], sig)
+	case ed25519.PublicKey:
+		valid = ed25519.Verify(k, payload, sig)
+	}
+	if !valid {
+		return errors.New("signature does not match")
+	}
+	return nil
+}
+
+// parseDevicePrivateKey decodes a PEM encoded PKCS #8 private key, for
+// clients signing readings on behalf of a device.
+func parseDevicePrivateKey(pemStr string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(pemStr))
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.New("unable to parse PKCS #8 private key")
+	}
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+		return key, nil
+	}
+	return nil, errors.New("unsupported key type, must be ECDSA or Ed25519")
+}
+
+// signTrackingDataPoint signs a data point the way
+// verifyTrackingDataPoint checks it. Returns the signature in base64.
+func signTrackingDataPoint(key interface{}, tdp TrackingDataPoint) (string, error) {
+	payload, err := tdp.signedPayload()
+	if err != nil {
+		return "", err
+	}
+	var sig []byte
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		digest := sha256.Sum256(payload)
+		sig, err = ecdsa.SignASN1(rand.Reader, k, digest[:])
+	case ed25519.PrivateKey:
+		sig = ed25519.Sign(k, payload)
+	default:
+		err = errors.New("unsupported key type, must be ECDSA or Ed25519")
+	}
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(sig), nil
+}
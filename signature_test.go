@@ -0,0 +1,189 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+	"testing"
+	"time"
+)
+
+// trackingSetup is an in-memory chaincode, called by an admin, with a
+// ShipmentCo owning an Ed25519 tracking device and a shipment from one
+// individual to another tracked by it
+type trackingSetup struct {
+	b          *memoryBackend
+	shipmentID string
+	deviceID   string
+	key        ed25519.PrivateKey
+}
+
+func newTrackingSetup(t *testing.T) *trackingSetup {
+	b, err := newMemoryBackend("admin", true, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &trackingSetup{b: b}
+
+	address := Address{Street: "Hauptstr.", HouseNumber: "1", PostalCode: "10115", City: "Berlin", Country: "DE"}
+	var res struct {
+		ID string `json:"id"`
+	}
+	s.invoke(t, "registerShipmentCo", registerShipmentCoArg{Name: "FastShip", Address: address}, &res)
+	shipmentCoID := res.ID
+	s.invoke(t, "registerIndividualParticipant", registerIndividualParticipantArg{Name: "Alice", Address: address}, &res)
+	fromID := res.ID
+	address.HouseNumber = "2"
+	s.invoke(t, "registerIndividualParticipant", registerIndividualParticipantArg{Name: "Bob", Address: address}, &res)
+	toID := res.ID
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.key = key
+	s.invoke(t, "registerDevice", registerDeviceArg{
+		Owner:           shipmentCoID,
+		PublicKey:       publicKeyPEM(t, pub),
+		Model:           "T1",
+		CalibrationDate: "2019-01-01T00:00:00Z",
+	}, &res)
+	s.deviceID = res.ID
+
+	s.invoke(t, "submitShipment", submitShipmentArg{
+		Shipper:     shipmentCoID,
+		From:        fromID,
+		To:          toID,
+		SubmittedAt: "2019-05-01T00:00:00Z",
+		Devices:     []string{s.deviceID},
+	}, &res)
+	s.shipmentID = res.ID
+	return s
+}
+
+// invoke calls a function which must succeed, and decodes its response
+// into res
+func (s *trackingSetup) invoke(t *testing.T, function string, arg interface{}, res interface{}) {
+	t.Helper()
+	data, err := json.Marshal(arg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := s.b.invoke(function, data)
+	if err != nil {
+		t.Fatalf("%s: %s", function, err)
+	}
+	if res != nil {
+		if err = json.Unmarshal(payload, res); err != nil {
+			t.Fatalf("%s: %s", function, err)
+		}
+	}
+}
+
+// reading returns a reading of the device, taken a minute per counter
+// after noon and signed by key
+func (s *trackingSetup) reading(t *testing.T, counter uint64, key ed25519.PrivateKey) trackShipmentArg {
+	t.Helper()
+	at := time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC).Add(time.Duration(counter) * time.Minute)
+	arg := trackShipmentArg{
+		ID: s.shipmentID,
+		trackingReadingArg: trackingReadingArg{
+			Device:      s.deviceID,
+			At:          at.Format(time.RFC3339),
+			Latitude:    52.5,
+			Longitude:   13.4,
+			Temperature: 4,
+			Humidity:    40,
+			Counter:     counter,
+		},
+	}
+	tdp, err := arg.toDataPoint(s.shipmentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if arg.Signature, err = signTrackingDataPoint(key, tdp); err != nil {
+		t.Fatal(err)
+	}
+	return arg
+}
+
+func publicKeyPEM(t *testing.T, pub interface{}) string {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+}
+
+func TestTrackShipmentChecksReadings(t *testing.T) {
+	_, otherKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		prior    []uint64 // counters of readings accepted before
+		revoke   bool     // revoke the device before
+		counter  uint64
+		wrongKey bool
+		tamper   func(arg *trackShipmentArg) // changes the reading after signing
+		err      string
+	}{
+		{name: "valid", counter: 1},
+		{name: "valid after others", prior: []uint64{1, 2}, counter: 3},
+		{
+			name:    "tampered payload",
+			counter: 1,
+			tamper:  func(arg *trackShipmentArg) { arg.Temperature = 25 },
+			err:     "signature does not match",
+		},
+		{
+			name:    "tampered time",
+			counter: 1,
+			tamper:  func(arg *trackShipmentArg) { arg.At = "2019-05-01T13:00:00Z" },
+			err:     "signature does not match",
+		},
+		{name: "wrong key", counter: 1, wrongKey: true, err: "signature does not match"},
+		{name: "replayed counter", prior: []uint64{1}, counter: 1, err: "Must be greater than last counter"},
+		{name: "decreasing counter", prior: []uint64{5}, counter: 3, err: "Must be greater than last counter"},
+		{name: "revoked device", revoke: true, counter: 1, err: "Not active"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTrackingSetup(t)
+			for _, counter := range tt.prior {
+				s.invoke(t, "trackShipment", s.reading(t, counter, s.key), nil)
+			}
+			if tt.revoke {
+				s.invoke(t, "revokeDevice", revokeDeviceArg{ID: s.deviceID}, nil)
+			}
+
+			key := s.key
+			if tt.wrongKey {
+				key = otherKey
+			}
+			arg := s.reading(t, tt.counter, key)
+			if tt.tamper != nil {
+				tt.tamper(&arg)
+			}
+			data, err := json.Marshal(arg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, err = s.b.invoke("trackShipment", data)
+
+			if tt.err == "" && err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+				t.Fatalf("expected error containing %q, got %v", tt.err, err)
+			}
+		})
+	}
+}
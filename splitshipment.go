@@ -0,0 +1,223 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	splitShipmentSchema = `
+{
+	"$id": "PreciousCargoShippping:splitShipmentSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment to split"
+		},
+		"parts": {
+			"type": "array",
+			"minItems": 2,
+			"items": {
+				"type": "object",
+				"properties": {
+					"manifest": {
+						"type": "array",
+						"minItems": 1,
+						"items": {
+							"type": "object",
+							"properties": {
+								"sku": { "type": "string" },
+								"quantity": { "type": "integer", "minimum": 1 }
+							},
+							"required": [ "sku", "quantity" ]
+						}
+					},
+					"devices": {
+						"type": "array",
+						"items": { "type": "string" }
+					}
+				},
+				"required": [ "manifest" ]
+			}
+		}
+	},
+	"required": [ "id", "parts" ]
+}
+`
+	splitShipmentSchemaLoader = gojsonschema.NewStringLoader(splitShipmentSchema)
+)
+
+// Splits a shipment into parts. The manifests of all parts must add up to
+// the manifest of the shipment.
+type splitShipmentArg struct {
+	ID    string                 `json:"id"`
+	Parts []splitShipmentPartArg `json:"parts"`
+}
+
+type splitShipmentPartArg struct {
+	Manifest []ManifestItem `json:"manifest"`
+	Devices  []string       `json:"devices"` // travelling with this part
+}
+
+// Returns ID of split shipment and IDs of its parts
+type splitShipmentResult struct {
+	ID       string   `json:"id"`
+	ChildIDs []string `json:"children"`
+}
+
+type splitShipmentInvocation struct {
+	eventRecorder
+
+	arg splitShipmentArg
+
+	shipment *Shipment
+
+	res splitShipmentResult
+}
+
+func (inv *splitShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter splitShipmentInvocation.checkParseArguments")
+
+	inv.arg = splitShipmentArg{}
+	err := parseArgument(stub, splitShipmentSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	inv.shipment = x.(*Shipment)
+	inv.shipment.ensureLegs()
+
+	if err = inv.shipment.checkActive(); err != nil {
+		return err
+	}
+	if inv.shipment.Status == ShipmentDelivered {
+		return errors.New("shipment has been delivered already")
+	}
+	if len(inv.shipment.Manifest) == 0 {
+		return errors.New("shipment has no manifest to split")
+	}
+
+	// parts must partition the manifest
+	remaining := map[string]int{}
+	for _, item := range inv.shipment.Manifest {
+		remaining[item.SKU] = item.Quantity
+	}
+	devices := map[string]bool{}
+	for i, part := range inv.arg.Parts {
+		if err = checkManifest(part.Manifest); err != nil {
+			return fmt.Errorf("invalid parts argument: part %d: %s", i, err)
+		}
+		for _, item := range part.Manifest {
+			if _, found := remaining[item.SKU]; !found {
+				return fmt.Errorf("invalid parts argument: part %d: sku %s not in manifest", i, item.SKU)
+			}
+			remaining[item.SKU] -= item.Quantity
+		}
+		if err = checkDevices(stub, inv.shipment.Legs, part.Devices); err != nil {
+			return fmt.Errorf("invalid parts argument: part %d: %s", i, err)
+		}
+		for _, deviceID := range part.Devices {
+			if devices[deviceID] {
+				return fmt.Errorf("invalid parts argument: device %s in more than one part", deviceID)
+			}
+			devices[deviceID] = true
+		}
+	}
+	for sku, quantity := range remaining {
+		if quantity != 0 {
+			return fmt.Errorf("invalid parts argument: quantities of sku %s do not add up to manifest", sku)
+		}
+	}
+
+	return nil
+}
+
+func (inv *splitShipmentInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter splitShipmentInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	s := inv.shipment
+	if err = checkCallerHasCustody(stub, s, now); err != nil {
+		return err
+	}
+
+	descriptions := map[string]string{}
+	for _, item := range s.Manifest {
+		descriptions[item.SKU] = item.Description
+	}
+
+	// saving a part does not let the next one see the ID counter it
+	// updated, so the IDs of all parts are taken at once
+	ids, err := newIDs(stub, shipmentRegistry().typeStr, len(inv.arg.Parts))
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error generating index key")
+	}
+
+	// parts continue where the shipment is now, with the same legs, in
+	// the same container and with the same flags
+	for i, part := range inv.arg.Parts {
+		manifest := []ManifestItem{}
+		for _, item := range part.Manifest {
+			item.Description = descriptions[item.SKU]
+			manifest = append(manifest, item)
+		}
+
+		child := &Shipment{
+			Asset:       Asset{ID: ID{ID: ids[i]}},
+			ShipperID:   s.ShipperID,
+			FromID:      s.FromID,
+			ToID:        s.ToID,
+			Status:      s.Status,
+			Legs:        append([]Leg{}, s.Legs...),
+			DeviceIDs:   part.Devices,
+			Manifest:    manifest,
+			ParentID:    s.ID.ID,
+			SubmittedAt: s.SubmittedAt,
+			Route:       s.Route,
+			RouteState:  s.RouteState,
+			ContainerID: s.ContainerID,
+			Flags:       append([]ShipmentFlag{}, s.Flags...),
+		}
+		id, err := saveShipment(stub, &inv.eventRecorder, child)
+		if err != nil {
+			return err
+		}
+		s.ChildIDs = append(s.ChildIDs, id)
+	}
+
+	s.Status = ShipmentSplit
+	if _, err = saveShipment(stub, &inv.eventRecorder, s); err != nil {
+		return err
+	}
+	if err = replaceLoadedShipment(stub, s.ContainerID, s.ID.ID, s.ChildIDs); err != nil {
+		return err
+	}
+
+	inv.res = splitShipmentResult{
+		ID:       s.ID.ID,
+		ChildIDs: s.ChildIDs,
+	}
+	inv.emit("ShipmentSplit", inv.res)
+
+	return nil
+}
+
+func (inv *splitShipmentInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -3,18 +3,135 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
-	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"fmt"
 	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
 )
 
+var (
+	geoPointSchema = `{
+		"type": "object",
+		"properties": {
+			"lat": { "type": "number", "minimum": -90, "maximum": 90 },
+			"lng": { "type": "number", "minimum": -180, "maximum": 180 }
+		},
+		"required": [ "lat", "lng" ]
+	}`
+
+	geofenceSchema = fmt.Sprintf(`{
+		"type": "object",
+		"properties": {
+			"name": { "type": "string", "maxLength": 100 },
+			"polygon": { "type": "array", "minItems": 3, "items": %s }
+		},
+		"required": [ "polygon" ]
+	}`, geoPointSchema)
+
+	submitShipmentSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:submitShipmentSchema",
+	"type": "object",
+	"properties": {
+		"by": {
+			"type": "string",
+			"description": "ID of the shipper, a ShipmentCo",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"from": {
+			"type": "string",
+			"description": "ID of the sender",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"to": {
+			"type": "string",
+			"description": "ID of the recipient",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"submittedAt": {
+			"type": "string",
+			"description": "time in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		},
+		"legs": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"carrier": { "type": "string", "pattern": "^([0-9]{4,32})$" },
+					"originHub": { "type": "string" },
+					"destinationHub": { "type": "string" },
+					"plannedDeparture": { "type": "string" },
+					"plannedArrival": { "type": "string" }
+				},
+				"required": [ "carrier", "plannedDeparture", "plannedArrival" ]
+			}
+		},
+		"devices": {
+			"type": "array",
+			"items": { "type": "string" }
+		},
+		"manifest": {
+			"type": "array",
+			"items": {
+				"type": "object",
+				"properties": {
+					"sku": { "type": "string", "minLength": 1 },
+					"description": { "type": "string" },
+					"quantity": { "type": "integer", "minimum": 1 }
+				},
+				"required": [ "sku", "quantity" ]
+			}
+		},
+		"route": {
+			"type": "object",
+			"properties": {
+				"origin": %s,
+				"destination": %s,
+				"waypoints": { "type": "array", "items": %s },
+				"corridor": { "type": "array", "items": %s },
+				"corridorWidthKm": { "type": "number", "minimum": 0 }
+			}
+		}
+	},
+	"required": [ "by", "from", "to", "submittedAt" ]
+}
+`, geofenceSchema, geofenceSchema, geofenceSchema, geoPointSchema)
+	submitShipmentSchemaLoader = gojsonschema.NewStringLoader(submitShipmentSchema)
+)
+
+// kinds of participants which may send or receive shipments
+var senderRecipientKinds = []string{KindIndividualParticipant, KindCorporateParticipant}
+
 // creates a new shipment structure from given Ids of shipper and Participants.
 type submitShipmentArg struct {
 	Shipper     string `json:"by"`
 	From        string `json:"from"`
 	To          string `json:"to"`
 	SubmittedAt string `json:"submittedAt"`
+
+	// optional legs. Without legs, the shipper carries the shipment
+	// from start to end.
+	Legs []submitShipmentLegArg `json:"legs,omitempty"`
+
+	// IDs of tracking devices, must be owned by the carriers
+	Devices []string `json:"devices,omitempty"`
+
+	// optional list of goods
+	Manifest []ManifestItem `json:"manifest,omitempty"`
+
+	// optional planned route
+	Route *PlannedRoute `json:"route,omitempty"`
+}
+
+// a planned leg of a shipment, times in RFC3339
+type submitShipmentLegArg struct {
+	Carrier          string `json:"carrier"`
+	OriginHub        string `json:"originHub"`
+	DestinationHub   string `json:"destinationHub"`
+	PlannedDeparture string `json:"plannedDeparture"`
+	PlannedArrival   string `json:"plannedArrival"`
 }
 
 // Returns ID of shipment
@@ -23,84 +140,113 @@ type submitShipmentResult struct {
 }
 
 type submitShipmentInvocation struct {
+	eventRecorder
+
 	// input argument
 	arg submitShipmentArg
 
 	// intermediates
-	shipperKey, fromKey, toKey string
-	submittedAtParsed          time.Time
+	shipperKey, fromKind, toKind string
+	submittedAtParsed            time.Time
+	legs                         []Leg
 
 	// result
 	res submitShipmentResult
 }
 
 func (inv *submitShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter submitShipmentInvocation.checkParseArguments")
-
-	_, args := stub.GetFunctionAndParameters()
-
-	if len(args) != 1 {
-		return errors.New("expecting JSON input as first param")
-	}
+	loggerFor(stub).Debug("enter submitShipmentInvocation.checkParseArguments")
 
 	inv.arg = submitShipmentArg{}
-	err := json.Unmarshal([]byte(args[0]), &inv.arg)
+	err := parseArgument(stub, submitShipmentSchemaLoader, &inv.arg)
 	if err != nil {
-		logger.Printf("Error unmarshaling JSON: %s", err)
-		return errors.New("Invalid JSON")
+		return err
 	}
 
 	// check IDs
 	var k string
-	k, _, err = shipmentCoRegistry().get(stub, inv.arg.Shipper)
+	var shipper interface{}
+	k, shipper, err = shipmentCoRegistry().get(stub, inv.arg.Shipper)
 	if err != nil {
-		logger.Println(err)
+		loggerFor(stub).Warn(err)
 		return errors.New("invalid shipper argument: Not found")
 	}
+	if !shipper.(*ShipmentCo).active() {
+		return errors.New("invalid shipper argument: Deactivated")
+	}
 	inv.shipperKey = k
 
-	k, _, err = individualParticipantRegistry().get(stub, inv.arg.From)
-	if err != nil {
+	// senders and recipients are individuals or companies
+	inv.fromKind, _, err = getActiveParticipant(stub, inv.arg.From, senderRecipientKinds...)
+	if err == errNotFound {
 		return errors.New("invalid from argument: Not found")
 	}
-	inv.fromKey = k
-
-	k, _, err = individualParticipantRegistry().get(stub, inv.arg.To)
 	if err != nil {
+		loggerFor(stub).Warn(err)
+		return fmt.Errorf("invalid from argument: %s", err)
+	}
+
+	inv.toKind, _, err = getActiveParticipant(stub, inv.arg.To, senderRecipientKinds...)
+	if err == errNotFound {
 		return errors.New("invalid to argument: Not found")
 	}
-	inv.toKey = k
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return fmt.Errorf("invalid to argument: %s", err)
+	}
+
+	// check legs
+	inv.legs, err = parseLegs(stub, inv.arg.Shipper, inv.arg.Legs)
+	if err != nil {
+		return fmt.Errorf("invalid legs argument: %s", err)
+	}
+	if err = checkDevices(stub, inv.legs, inv.arg.Devices); err != nil {
+		return fmt.Errorf("invalid devices argument: %s", err)
+	}
+	if err = checkManifest(inv.arg.Manifest); err != nil {
+		return fmt.Errorf("invalid manifest argument: %s", err)
+	}
+
+	if inv.arg.Route != nil {
+		if err = inv.arg.Route.validate(); err != nil {
+			return fmt.Errorf("invalid route argument: %s", err)
+		}
+	}
 
 	// parse and check time
 	inv.submittedAtParsed, err = time.Parse(time.RFC3339, inv.arg.SubmittedAt)
 	if err != nil {
 		return errors.New("invalid submittedAt argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
 	}
-	logger.Printf("Parsed submittedAt=%s\n", inv.submittedAtParsed)
+	loggerFor(stub).Debugf("Parsed submittedAt=%s", inv.submittedAtParsed)
 
 	// check against now, e.g. diff must be <1h or the like
 	difference := time.Now().Sub(inv.submittedAtParsed)
-	logger.Printf("Diff to now is=%s\n", difference)
+	loggerFor(stub).Debugf("Diff to now is=%s", difference)
 
 	return nil
 }
 
 func (inv *submitShipmentInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter submitShipmentInvocation.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter submitShipmentInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
 
-	key, err := shipmentRegistry().create(stub, Shipment{
+	id, err := saveShipment(stub, &inv.eventRecorder, &Shipment{
 		ShipperID:   inv.arg.Shipper,
 		FromID:      inv.arg.From,
 		ToID:        inv.arg.To,
-		Status:      "submitted",
+		Status:      ShipmentSubmitted,
+		Legs:        inv.legs,
+		DeviceIDs:   inv.arg.Devices,
+		Manifest:    inv.arg.Manifest,
 		SubmittedAt: inv.submittedAtParsed,
+		Route:       inv.arg.Route,
 	})
 	if err != nil {
 		return errors.New("internal error writing world state")
 	}
 	inv.res = submitShipmentResult{
-		ID: key,
+		ID: id,
 	}
 
 	return nil
@@ -109,3 +255,101 @@ func (inv *submitShipmentInvocation) process(stub shim.ChaincodeStubInterface) e
 func (inv *submitShipmentInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
 	return inv.res
 }
+
+// parseLegs checks the legs given by a client. Carriers must exist and
+// be active, each leg must start where the previous one ended, and
+// planned times must be in order. Without legs, a single leg carried by
+// the shipper is returned.
+func parseLegs(stub shim.ChaincodeStubInterface, shipper string, args []submitShipmentLegArg) ([]Leg, error) {
+	if len(args) == 0 {
+		return []Leg{{CarrierID: shipper, Status: LegPlanned}}, nil
+	}
+
+	legs := []Leg{}
+	for i, arg := range args {
+		_, carrier, err := shipmentCoRegistry().get(stub, arg.Carrier)
+		if err != nil {
+			loggerFor(stub).Warn(err)
+			return nil, fmt.Errorf("leg %d: Carrier not found", i)
+		}
+		if !carrier.(*ShipmentCo).active() {
+			return nil, fmt.Errorf("leg %d: Carrier deactivated", i)
+		}
+
+		departure, err := time.Parse(time.RFC3339, arg.PlannedDeparture)
+		if err != nil {
+			return nil, fmt.Errorf("leg %d: plannedDeparture not parseable, please provide in RFC3339", i)
+		}
+		arrival, err := time.Parse(time.RFC3339, arg.PlannedArrival)
+		if err != nil {
+			return nil, fmt.Errorf("leg %d: plannedArrival not parseable, please provide in RFC3339", i)
+		}
+		if !arrival.After(departure) {
+			return nil, fmt.Errorf("leg %d: plannedArrival must be after plannedDeparture", i)
+		}
+
+		if i > 0 {
+			prev := legs[i-1]
+			if arg.OriginHub != prev.DestinationHub {
+				return nil, fmt.Errorf("leg %d: originHub must be destinationHub of previous leg", i)
+			}
+			if departure.Before(prev.PlannedArrival) {
+				return nil, fmt.Errorf("leg %d: plannedDeparture is before plannedArrival of previous leg", i)
+			}
+		}
+
+		legs = append(legs, Leg{
+			CarrierID:        arg.Carrier,
+			OriginHub:        arg.OriginHub,
+			DestinationHub:   arg.DestinationHub,
+			PlannedDeparture: departure.UTC(),
+			PlannedArrival:   arrival.UTC(),
+			Status:           LegPlanned,
+		})
+	}
+	return legs, nil
+}
+
+// checkDevices makes sure devices are active and belong to one of the
+// carriers of the given legs.
+func checkDevices(stub shim.ChaincodeStubInterface, legs []Leg, devices []string) error {
+	carriers := map[string]bool{}
+	for _, leg := range legs {
+		carriers[leg.CarrierID] = true
+	}
+
+	seen := map[string]bool{}
+	for _, deviceID := range devices {
+		if seen[deviceID] {
+			return fmt.Errorf("duplicate device %s", deviceID)
+		}
+		seen[deviceID] = true
+
+		d, err := getActiveDevice(stub, deviceID)
+		if err != nil {
+			return err
+		}
+		if !carriers[d.OwnerID] {
+			return fmt.Errorf("device %s is not owned by a carrier", deviceID)
+		}
+	}
+	return nil
+}
+
+// checkManifest makes sure each SKU is listed once with a positive quantity
+func checkManifest(manifest []ManifestItem) error {
+	seen := map[string]bool{}
+	for _, item := range manifest {
+		if item.SKU == "" {
+			return errors.New("item without sku")
+		}
+		if seen[item.SKU] {
+			return fmt.Errorf("duplicate sku %s", item.SKU)
+		}
+		seen[item.SKU] = true
+		if item.Quantity <= 0 {
+			return fmt.Errorf("sku %s: quantity must be positive", item.SKU)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// updateTrackingSummary adds data points written in this transaction to the
+// summary of their shipment. The shipment is not saved.
+//
+// Distance follows the points in order of measurement time. As long as new
+// points are newer than everything seen so far, it is extended from the last
+// position. A point measured earlier than that (an out-of-order upload) falls
+// between two known points: the segment between them is replaced by the two
+// segments to and from the new point.
+func updateTrackingSummary(stub shim.ChaincodeStubInterface, shipment *Shipment, points []TrackingDataPoint) error {
+	if len(points) == 0 {
+		return nil
+	}
+	sort.SliceStable(points, func(i, j int) bool {
+		if points[i].At.Equal(points[j].At) {
+			return points[i].DeviceID < points[j].DeviceID
+		}
+		return points[i].At.Before(points[j].At)
+	})
+	keys := make([]string, len(points))
+	skip := map[string]bool{}
+	for i, tdp := range points {
+		key, err := trackingDataPointKey(stub, tdp)
+		if err != nil {
+			return err
+		}
+		keys[i] = key
+		skip[key] = true
+	}
+
+	s := shipment.Summary
+	if s == nil {
+		s = &TrackingSummary{}
+		shipment.Summary = s
+	}
+
+	// the point of this transaction added last, which is not stored yet
+	var added *trackingNeighbour
+	for i, tdp := range points {
+		if s.Count == 0 {
+			s.FirstSeen, s.LastSeen = tdp.At, tdp.At
+			s.MinTemperature, s.MaxTemperature = tdp.Temperature, tdp.Temperature
+			s.MinHumidity, s.MaxHumidity = tdp.Humidity, tdp.Humidity
+			s.LastPosition = tdp.position()
+		}
+
+		if !tdp.At.Before(s.LastSeen) {
+			s.DistanceKm += haversineKm(s.LastPosition, tdp.position())
+			s.LastSeen = tdp.At
+			s.LastPosition = tdp.position()
+		} else {
+			prev, next, err := trackingNeighbours(stub, shipment.ID.ID, keys[i], tdp.At, s.FirstSeen, s.LastSeen, skip)
+			if err != nil {
+				return err
+			}
+			if added != nil && (prev == nil || added.key > prev.key) {
+				prev = added
+			}
+			if prev != nil {
+				s.DistanceKm += haversineKm(prev.tdp.position(), tdp.position())
+			}
+			if next != nil {
+				s.DistanceKm += haversineKm(tdp.position(), next.tdp.position())
+			}
+			if prev != nil && next != nil {
+				s.DistanceKm -= haversineKm(prev.tdp.position(), next.tdp.position())
+			}
+		}
+		added = &trackingNeighbour{key: keys[i], tdp: tdp}
+
+		s.Count++
+		if tdp.At.Before(s.FirstSeen) {
+			s.FirstSeen = tdp.At
+		}
+		s.MinTemperature = min32(s.MinTemperature, tdp.Temperature)
+		s.MaxTemperature = max32(s.MaxTemperature, tdp.Temperature)
+		s.AvgTemperature += (tdp.Temperature - s.AvgTemperature) / float32(s.Count)
+		s.MinHumidity = min32(s.MinHumidity, tdp.Humidity)
+		s.MaxHumidity = max32(s.MaxHumidity, tdp.Humidity)
+		s.AvgHumidity += (tdp.Humidity - s.AvgHumidity) / float32(s.Count)
+	}
+
+	return nil
+}
+
+func min32(a, b float32) float32 {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max32(a, b float32) float32 {
+	if a > b {
+		return a
+	}
+	return b
+}
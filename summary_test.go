@@ -0,0 +1,80 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestUpdateTrackingSummaryOutOfOrder(t *testing.T) {
+	stub := shim.NewMockStub(ns, &PreciousCargoChaincode{handlers: handlers})
+	shipment := &Shipment{Asset: Asset{ID: ID{ID: "0000000001"}}}
+
+	// point n is measured n hours after the start, the ones on later days
+	// leave days without points in between
+	point := func(n int) TrackingDataPoint {
+		return TrackingDataPoint{
+			ShipmentID:  ID{"0000000001"},
+			DeviceID:    "0000000001",
+			At:          time.Date(2019, 5, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(n*n) * time.Hour),
+			Latitude:    50 + float64(n%3),
+			Longitude:   10 + float64(n),
+			Temperature: float32(n),
+		}
+	}
+	uploads := [][]int{{5}, {1}, {3}, {9, 2, 4}, {6}, {8, 0, 7}, {10}}
+
+	all := []TrackingDataPoint{}
+	for i, upload := range uploads {
+		txID := fmt.Sprintf("tx%d", i)
+		stub.MockTransactionStart(txID)
+		points := []TrackingDataPoint{}
+		for _, n := range upload {
+			if _, err := putTrackingDataPoint(stub, point(n)); err != nil {
+				t.Fatal(err)
+			}
+			points = append(points, point(n))
+		}
+		if err := updateTrackingSummary(stub, shipment, points); err != nil {
+			t.Fatal(err)
+		}
+		stub.MockTransactionEnd(txID)
+
+		// the summary must be the same as if computed from all points
+		for _, n := range upload {
+			all = append(all, point(n))
+		}
+		sorted := []TrackingDataPoint{}
+		for n := 0; n <= 10; n++ {
+			for _, tdp := range all {
+				if tdp.At.Equal(point(n).At) {
+					sorted = append(sorted, tdp)
+				}
+			}
+		}
+		first, last := sorted[0], sorted[len(sorted)-1]
+		distance := 0.0
+		for j := 1; j < len(sorted); j++ {
+			distance += haversineKm(sorted[j-1].position(), sorted[j].position())
+		}
+
+		s := shipment.Summary
+		if s.Count != len(all) {
+			t.Errorf("upload %d: expected count %d, got %d", i, len(all), s.Count)
+		}
+		if !s.FirstSeen.Equal(first.At) || !s.LastSeen.Equal(last.At) {
+			t.Errorf("upload %d: expected %s to %s, got %s to %s", i, first.At, last.At, s.FirstSeen, s.LastSeen)
+		}
+		if s.LastPosition != last.position() {
+			t.Errorf("upload %d: expected last position %v, got %v", i, last.position(), s.LastPosition)
+		}
+		if math.Abs(s.DistanceKm-distance) > 1e-6 {
+			t.Errorf("upload %d: expected distance %f km, got %f km", i, distance, s.DistanceKm)
+		}
+	}
+}
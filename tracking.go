@@ -0,0 +1,198 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+// Tracking data points are stored under
+//
+//	ns . TrackingDataPoint # <shipment id> <day> <time of day> <device id>
+//
+// with day and time in UTC, so that a partial key scan returns the points
+// of a shipment in order of measurement, not arrival. A day bucket is the
+// smallest unit of a scan, so scanning a time range only reads the days
+// it touches. The device id breaks ties between devices measuring at the
+// same time. A device can not measure twice at the same time, so a point
+// whose key exists already is a duplicate.
+const (
+	trackingDataPointType = "TrackingDataPoint"
+	sortableDayFormat     = "2006-01-02"
+	sortableTimeFormat    = "15:04:05.000000000"
+)
+
+// sortableTimeAttrs returns the day and time of day attributes of a key
+// for a point in time, which sort in order of time
+func sortableTimeAttrs(t time.Time) []string {
+	t = t.UTC()
+	return []string{t.Format(sortableDayFormat), t.Format(sortableTimeFormat)}
+}
+
+var errDuplicateDataPoint = errors.New("invalid at argument: Reading of this device for this time already exists")
+
+// Emitted when tracking data points of a shipment have been stored
+type trackingDataEvent struct {
+	ShipmentID string              `json:"shipmentId"`
+	Points     []TrackingDataPoint `json:"points"`
+}
+
+// trackingDataPointKey derives the key of a data point from its shipment,
+// its measurement time and its device.
+func trackingDataPointKey(stub shim.ChaincodeStubInterface, tdp TrackingDataPoint) (string, error) {
+	attrs := append([]string{".", trackingDataPointType, "#", tdp.ShipmentID.ID}, sortableTimeAttrs(tdp.At)...)
+	ck, err := stub.CreateCompositeKey(ns, append(attrs, tdp.DeviceID))
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal error generating composite key")
+	}
+	return ck, nil
+}
+
+// putTrackingDataPoint writes a new data point, rejecting duplicates.
+// Returns the key of the data point.
+func putTrackingDataPoint(stub shim.ChaincodeStubInterface, tdp TrackingDataPoint) (string, error) {
+	ck, err := trackingDataPointKey(stub, tdp)
+	if err != nil {
+		return "", err
+	}
+	data, err := stub.GetState(ck)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal error reading from world state")
+	}
+	if data != nil {
+		return "", errDuplicateDataPoint
+	}
+
+	data, err = json.Marshal(tdp)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal JSON marshal error")
+	}
+	err = stub.PutState(ck, data)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return "", errors.New("internal error writing world state")
+	}
+	loggerFor(stub).Debugf("PutState to key=%s, data=%v", ck, tdp)
+
+	return ck, nil
+}
+
+// maxDayScans is the number of days up to which a time range is scanned
+// day by day. Longer ranges are scanned in one.
+const maxDayScans = 31
+
+// scanTrackingDataPoints calls fn for all data points of a shipment measured
+// in [from, to), in order of measurement time. A zero from or to leaves the
+// range open at that end.
+func scanTrackingDataPoints(stub shim.ChaincodeStubInterface, shipmentID string, from, to time.Time, fn func(TrackingDataPoint) error) error {
+	prefix := []string{".", trackingDataPointType, "#", shipmentID}
+
+	withKey := func(key string, tdp TrackingDataPoint) error {
+		return fn(tdp)
+	}
+
+	first := from.UTC().Truncate(24 * time.Hour)
+	if from.IsZero() || to.IsZero() || to.Sub(first) > maxDayScans*24*time.Hour {
+		// open or long range, scan everything of this shipment up to its end
+		return scanTrackingDataPointsByKey(stub, prefix, from, to, withKey)
+	}
+
+	// scan day by day
+	for day := first; day.Before(to); day = day.Add(24 * time.Hour) {
+		err := scanTrackingDataPointsByKey(stub, append(prefix, day.Format(sortableDayFormat)), from, to, withKey)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// trackingNeighbour is a stored data point and its key
+type trackingNeighbour struct {
+	key string
+	tdp TrackingDataPoint
+}
+
+// errScanDone ends a scan early
+var errScanDone = errors.New("scan done")
+
+// trackingNeighbours looks up the data points of a shipment stored right
+// before and after the one with the given key, measured at. Days are
+// scanned one at a time, from the day of at back to the day of first and
+// forward to the day of last, until a point is found. Points with keys in
+// skip are ignored: they are written in this transaction, which a peer
+// does not show to reads yet, but MockStub does.
+func trackingNeighbours(stub shim.ChaincodeStubInterface, shipmentID, key string, at, first, last time.Time, skip map[string]bool) (prev, next *trackingNeighbour, err error) {
+	prefix := []string{".", trackingDataPointType, "#", shipmentID}
+	day := at.UTC().Truncate(24 * time.Hour)
+
+	for d := day; prev == nil && !d.Before(first.UTC().Truncate(24*time.Hour)); d = d.Add(-24 * time.Hour) {
+		err = scanTrackingDataPointsByKey(stub, append(prefix, d.Format(sortableDayFormat)), time.Time{}, time.Time{}, func(k string, tdp TrackingDataPoint) error {
+			if k >= key {
+				return errScanDone
+			}
+			if !skip[k] {
+				prev = &trackingNeighbour{key: k, tdp: tdp}
+			}
+			return nil
+		})
+		if err != nil && err != errScanDone {
+			return nil, nil, err
+		}
+	}
+
+	for d := day; next == nil && !d.After(last); d = d.Add(24 * time.Hour) {
+		err = scanTrackingDataPointsByKey(stub, append(prefix, d.Format(sortableDayFormat)), time.Time{}, time.Time{}, func(k string, tdp TrackingDataPoint) error {
+			if k <= key || skip[k] {
+				return nil
+			}
+			next = &trackingNeighbour{key: k, tdp: tdp}
+			return errScanDone
+		})
+		if err != nil && err != errScanDone {
+			return nil, nil, err
+		}
+	}
+	return prev, next, nil
+}
+
+func scanTrackingDataPointsByKey(stub shim.ChaincodeStubInterface, keys []string, from, to time.Time, fn func(string, TrackingDataPoint) error) error {
+	it, err := stub.GetStateByPartialCompositeKey(ns, keys)
+	if err != nil {
+		loggerFor(stub).Error(err)
+		return errors.New("internal error reading from world state")
+	}
+	defer it.Close()
+
+	for it.HasNext() {
+		kv, err := it.Next()
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state")
+		}
+		var tdp TrackingDataPoint
+		err = json.Unmarshal(kv.Value, &tdp)
+		if err != nil {
+			loggerFor(stub).Error(err)
+			return errors.New("internal error reading from world state (2)")
+		}
+		if !from.IsZero() && tdp.At.Before(from) {
+			continue
+		}
+		if !to.IsZero() && !tdp.At.Before(to) {
+			// keys are sorted by time, nothing more to find
+			break
+		}
+		if err = fn(kv.Key, tdp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+)
+
+func TestScanTrackingDataPoints(t *testing.T) {
+	stub := shim.NewMockStub(ns, &PreciousCargoChaincode{handlers: handlers})
+	day := func(n int) time.Time {
+		return time.Date(2019, 5, 1, 12, 0, 0, 0, time.UTC).AddDate(0, 0, n)
+	}
+
+	stub.MockTransactionStart("put")
+	for _, n := range []int{0, 1, 20, 45, 90} {
+		if _, err := putTrackingDataPoint(stub, TrackingDataPoint{ShipmentID: ID{"0000000001"}, DeviceID: "0000000001", At: day(n)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// another shipment, which must not be found
+	if _, err := putTrackingDataPoint(stub, TrackingDataPoint{ShipmentID: ID{"0000000002"}, DeviceID: "0000000002", At: day(20)}); err != nil {
+		t.Fatal(err)
+	}
+	stub.MockTransactionEnd("put")
+
+	tests := []struct {
+		name     string
+		from, to time.Time
+		days     []int
+	}{
+		{name: "open", days: []int{0, 1, 20, 45, 90}},
+		{name: "open end", from: day(20), days: []int{20, 45, 90}},
+		{name: "open start", to: day(20), days: []int{0, 1}},
+		{name: "day by day", from: day(1), to: day(20).Add(time.Second), days: []int{1, 20}},
+		{name: "within a day", from: day(1).Add(-time.Hour), to: day(1).Add(time.Hour), days: []int{1}},
+		{name: "long", from: day(1), to: day(90), days: []int{1, 20, 45}},
+		{name: "empty", from: day(2), to: day(19), days: []int{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			found := []time.Time{}
+			err := scanTrackingDataPoints(stub, "0000000001", tt.from, tt.to, func(tdp TrackingDataPoint) error {
+				found = append(found, tdp.At)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(found) != len(tt.days) {
+				t.Fatalf("expected %d data points, got %v", len(tt.days), found)
+			}
+			for i, n := range tt.days {
+				if !found[i].Equal(day(n)) {
+					t.Errorf("expected data point %d at %s, got %s", i, day(n), found[i])
+				}
+			}
+		})
+	}
+}
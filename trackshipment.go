@@ -3,104 +3,216 @@
 package main
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
-	"github.com/hyperledger/fabric/core/chaincode/shim"
-	"reflect"
 	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
 )
 
-// Retrieves Participant data by Id, returns data structure
-type trackShipmentArg struct {
-	ID          string  `json:"id"`
-	At          string  `json:"at"` // time in RFC3339, e.g. 2006-01-02T15:04:05Z
+var (
+	// a single reading, as part of an argument
+	trackingReadingSchema = `{
+		"type": "object",
+		"properties": {
+			"device": { "type": "string" },
+			"at": { "type": "string" },
+			"lat": { "type": "number", "minimum": -90, "maximum": 90 },
+			"lng": { "type": "number", "minimum": -180, "maximum": 180 },
+			"temp": { "type": "number" },
+			"hum": { "type": "number" },
+			"counter": { "type": "integer", "minimum": 1 },
+			"sig": { "type": "string" }
+		},
+		"required": [ "device", "at", "lat", "lng", "counter", "sig" ]
+	}`
+
+	trackShipmentSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:trackShipmentSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		}
+	},
+	"required": [ "id" ],
+	"allOf": [ %s ]
+}
+`, trackingReadingSchema)
+	trackShipmentSchemaLoader = gojsonschema.NewStringLoader(trackShipmentSchema)
+)
+
+// A single reading of a tracking device
+type trackingReadingArg struct {
+	Device      string  `json:"device"` // ID of a Device assigned to the shipment
+	At          string  `json:"at"`     // time in RFC3339, e.g. 2006-01-02T15:04:05Z
 	Latitude    float64 `json:"lat"`
 	Longitude   float64 `json:"lng"`
 	Temperature float32 `json:"temp"`
 	Humidity    float32 `json:"hum"` // in [%]
+	Counter     uint64  `json:"counter"`
+	Signature   string  `json:"sig"` // base64, see signedPayloadV1
+}
+
+// Adds a reading to the shipment with given Id
+type trackShipmentArg struct {
+	ID string `json:"id"`
+	trackingReadingArg
 }
 
 type trackShipmentInvocation struct {
+	eventRecorder
+
 	arg trackShipmentArg
 
-	at          time.Time
 	shipmentKey string
-	shipment    Shipment
+	shipment    *Shipment
+	device      *Device
+	tdp         TrackingDataPoint
 
 	res struct{}
 }
 
-func (inv *trackShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter trackShipmentInvocation.checkParseArguments")
+// toDataPoint parses and checks a reading and turns it into a data point
+// for the given shipment.
+func (r trackingReadingArg) toDataPoint(shipmentID string) (TrackingDataPoint, error) {
+	// parse time
+	at, err := time.Parse(time.RFC3339, r.At)
+	if err != nil {
+		return TrackingDataPoint{}, errors.New("invalid at argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
+	}
+	// must be somewhat recent. (TODO)
+
+	// check humidity
+	if r.Humidity < 0 || r.Humidity > 100 {
+		return TrackingDataPoint{}, errors.New("invalid hum argument: Must be [0..100] [%]")
+	}
+
+	return TrackingDataPoint{
+		ShipmentID:  ID{shipmentID},
+		DeviceID:    r.Device,
+		Counter:     r.Counter,
+		At:          at.UTC(),
+		Latitude:    r.Latitude,
+		Longitude:   r.Longitude,
+		Temperature: r.Temperature,
+		Humidity:    r.Humidity,
+		Signature:   r.Signature,
+	}, nil
+}
+
+// checkTrackingDataPoint makes sure that a data point comes from an active
+// device assigned to the shipment and owned by the carrier of the leg at
+// the time of measurement, is signed by it and has not been seen before.
+// Devices are looked up in and added to the given cache, so that counters
+// of multiple data points are checked against each other.
+func checkTrackingDataPoint(stub shim.ChaincodeStubInterface, shipment *Shipment, tdp TrackingDataPoint, devices map[string]*Device) (*Device, error) {
+	// split or consolidated shipments are tracked through their relatives
+	if err := shipment.checkActive(); err != nil {
+		return nil, err
+	}
 
-	_, args := stub.GetFunctionAndParameters()
+	// only active devices assigned to this shipment may track it
+	assigned := false
+	for _, deviceID := range shipment.DeviceIDs {
+		if deviceID == tdp.DeviceID {
+			assigned = true
+		}
+	}
+	if !assigned {
+		return nil, errors.New("invalid device argument: Device not assigned to shipment")
+	}
+	device, found := devices[tdp.DeviceID]
+	if !found {
+		var err error
+		device, err = getActiveDevice(stub, tdp.DeviceID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid device argument: %s", err)
+		}
+		devices[tdp.DeviceID] = device
+	}
 
-	if len(args) != 1 {
-		return errors.New("expecting JSON input as first param")
+	// each carrier tracks its own leg only
+	leg := shipment.Legs[shipment.legAt(tdp.At)]
+	if device.OwnerID != leg.CarrierID {
+		return nil, errors.New("invalid device argument: Device not owned by carrier of the leg at this time")
+	}
+
+	// readings must be signed by the device, and must not be replayed
+	if err := verifyTrackingDataPoint(device, tdp); err != nil {
+		loggerFor(stub).Warn(err)
+		return nil, fmt.Errorf("invalid sig argument: %s", err)
+	}
+	if tdp.Counter <= device.LastCounter {
+		return nil, errors.New("invalid counter argument: Must be greater than last counter of device (replayed reading?)")
 	}
 
-	var err error
+	return device, nil
+}
+
+func (inv *trackShipmentInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter trackShipmentInvocation.checkParseArguments")
 
 	inv.arg = trackShipmentArg{}
-	err = json.Unmarshal([]byte(args[0]), &inv.arg)
+	err := parseArgument(stub, trackShipmentSchemaLoader, &inv.arg)
 	if err != nil {
-		logger.Printf("error unmarshaling JSON: %s", err)
-		return errors.New("Invalid JSON")
+		return err
 	}
 
-	// parse time
-	inv.at, err = time.Parse(time.RFC3339, inv.arg.At)
+	inv.tdp, err = inv.arg.toDataPoint(inv.arg.ID)
 	if err != nil {
-		return errors.New("invalid at argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
-	}
-	// must be somewhat recent. (TODO)
-
-	// check humidity
-	if inv.arg.Humidity < 0 || inv.arg.Humidity > 100 {
-		return errors.New("invalid hum argument: Must be [0..100] [%]")
+		return err
 	}
 
 	// load shipment
 	y, x, err := shipmentRegistry().get(stub, inv.arg.ID)
 	if err != nil {
-		println(err)
+		loggerFor(stub).Warn(err)
 		return errors.New("unable to locate shipment for this ID")
 	}
 	inv.shipmentKey = y
-	inv.shipment = x.(Shipment)
+	inv.shipment = x.(*Shipment)
+
+	inv.device, err = checkTrackingDataPoint(stub, inv.shipment, inv.tdp, map[string]*Device{})
+	if err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func (inv *trackShipmentInvocation) process(stub shim.ChaincodeStubInterface) error {
-	logger.Println("enter submitShipmentInvocation.process")
-	logger.Printf("arg=%#v\n", inv.arg)
+	loggerFor(stub).Debug("enter submitShipmentInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
 
-	// use a generic registry but with a special type string specific to
-	// the shipmennt
-	r := &registry{
-		typeStr: fmt.Sprintf("trackingDataPoint[%s]", inv.shipment.ID.ID),
-		typeRT:  reflect.TypeOf(&TrackingDataPoint{}),
+	key, err := putTrackingDataPoint(stub, inv.tdp)
+	if err != nil {
+		return err
 	}
+	loggerFor(stub).Debugf("Tracked: %s", key)
 
-	tdp := TrackingDataPoint{
-		ShipmentID:  ID{inv.arg.ID},
-		At:          inv.at,
-		Latitude:    inv.arg.Latitude,
-		Longitude:   inv.arg.Longitude,
-		Temperature: inv.arg.Temperature,
-		Humidity:    inv.arg.Humidity,
+	err = updateTrackingSummary(stub, inv.shipment, []TrackingDataPoint{inv.tdp})
+	if err != nil {
+		return err
 	}
-
-	key, err := r.create(stub, tdp)
+	events, err := evaluateRoute(stub, inv.shipment, []TrackingDataPoint{inv.tdp})
+	if err != nil {
+		return err
+	}
+	inv.emit("TrackingData", trackingDataEvent{ShipmentID: inv.shipment.ID.ID, Points: []TrackingDataPoint{inv.tdp}})
+	for _, ev := range events {
+		inv.emit(geofenceEventType, ev)
+	}
+	_, err = saveShipment(stub, nil, inv.shipment)
 	if err != nil {
-		logger.Println(err)
-		return errors.New("unable to write trackment data")
+		return err
 	}
-	logger.Printf("Tracked: %s\n", key)
 
-	return err
+	inv.device.LastCounter = inv.tdp.Counter
+	return deviceRegistry().update(stub, inv.device.ID.ID, inv.device)
 }
 
 func (inv *trackShipmentInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
@@ -0,0 +1,191 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// maximum number of readings accepted by trackShipmentBatch
+const maxTrackingBatchSize = 100
+
+var (
+	trackShipmentBatchSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:trackShipmentBatchSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		},
+		"readings": {
+			"type": "array",
+			"minItems": 1,
+			"maxItems": %d,
+			"items": %s
+		}
+	},
+	"required": [ "id", "readings" ]
+}
+`, maxTrackingBatchSize, trackingReadingSchema)
+	trackShipmentBatchSchemaLoader = gojsonschema.NewStringLoader(trackShipmentBatchSchema)
+)
+
+// Adds a number of readings to the shipment with given Id
+type trackShipmentBatchArg struct {
+	ID       string               `json:"id"`
+	Readings []trackingReadingArg `json:"readings"`
+}
+
+// Acceptance status of a single reading, by its index in the batch
+type trackShipmentBatchItemResult struct {
+	Index    int    `json:"index"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Returns number of accepted and rejected readings and status per item
+type trackShipmentBatchResult struct {
+	ID       string                         `json:"id"`
+	Accepted int                            `json:"accepted"`
+	Rejected int                            `json:"rejected"`
+	Items    []trackShipmentBatchItemResult `json:"items"`
+}
+
+type trackShipmentBatchInvocation struct {
+	eventRecorder
+
+	arg trackShipmentBatchArg
+
+	shipment *Shipment
+
+	res trackShipmentBatchResult
+}
+
+func (inv *trackShipmentBatchInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter trackShipmentBatchInvocation.checkParseArguments")
+
+	inv.arg = trackShipmentBatchArg{}
+	err := parseArgument(stub, trackShipmentBatchSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	// load shipment
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	inv.shipment = x.(*Shipment)
+
+	return nil
+}
+
+// process validates every reading on its own. Valid readings are written,
+// invalid ones are reported back but do not fail the transaction.
+func (inv *trackShipmentBatchInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter trackShipmentBatchInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	inv.res = trackShipmentBatchResult{
+		ID:    inv.arg.ID,
+		Items: make([]trackShipmentBatchItemResult, len(inv.arg.Readings)),
+	}
+
+	// check readings in counter order, so that a buffered upload
+	// which is not sorted is not mistaken for a replay
+	order := make([]int, len(inv.arg.Readings))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return inv.arg.Readings[order[a]].Counter < inv.arg.Readings[order[b]].Counter
+	})
+
+	devices := map[string]*Device{}
+	keys := map[string]bool{}
+	accepted := []TrackingDataPoint{}
+	for _, idx := range order {
+		item := &inv.res.Items[idx]
+		item.Index = idx
+
+		tdp, err := inv.storeReading(stub, inv.arg.Readings[idx], devices, keys)
+		if err != nil {
+			item.Error = err.Error()
+			inv.res.Rejected++
+			continue
+		}
+		item.Accepted = true
+		inv.res.Accepted++
+		accepted = append(accepted, tdp)
+	}
+
+	if len(accepted) > 0 {
+		if err := updateTrackingSummary(stub, inv.shipment, accepted); err != nil {
+			return err
+		}
+		events, err := evaluateRoute(stub, inv.shipment, accepted)
+		if err != nil {
+			return err
+		}
+		inv.emit("TrackingData", trackingDataEvent{ShipmentID: inv.shipment.ID.ID, Points: accepted})
+		for _, ev := range events {
+			inv.emit(geofenceEventType, ev)
+		}
+		if _, err := saveShipment(stub, nil, inv.shipment); err != nil {
+			return err
+		}
+	}
+
+	// save counters of all devices involved
+	for _, device := range devices {
+		err := deviceRegistry().update(stub, device.ID.ID, device)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// storeReading checks a single reading and writes it to the world state.
+func (inv *trackShipmentBatchInvocation) storeReading(stub shim.ChaincodeStubInterface, r trackingReadingArg, devices map[string]*Device, keys map[string]bool) (TrackingDataPoint, error) {
+	tdp, err := r.toDataPoint(inv.shipment.ID.ID)
+	if err != nil {
+		return tdp, err
+	}
+	device, err := checkTrackingDataPoint(stub, inv.shipment, tdp, devices)
+	if err != nil {
+		return tdp, err
+	}
+
+	// writes of this transaction are not visible to GetState, so
+	// duplicates within the batch are tracked here
+	key, err := trackingDataPointKey(stub, tdp)
+	if err != nil {
+		return tdp, err
+	}
+	if keys[key] {
+		return tdp, errDuplicateDataPoint
+	}
+	key, err = putTrackingDataPoint(stub, tdp)
+	if err != nil {
+		return tdp, err
+	}
+	keys[key] = true
+	device.LastCounter = tdp.Counter
+	loggerFor(stub).Debugf("Tracked: %s", key)
+
+	return tdp, nil
+}
+
+func (inv *trackShipmentBatchInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,93 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// typeSchema derives a JSON schema from a Go type, the way encoding/json
+// marshals it. It describes the shape only, i.e. knows nothing about
+// patterns or limits, so handwritten schemas are preferred where they exist.
+func typeSchema(t reflect.Type) map[string]interface{} {
+	return typeSchemaSeen(t, map[reflect.Type]bool{})
+}
+
+func typeSchemaSeen(t reflect.Type, seen map[reflect.Type]bool) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return typeSchemaSeen(t.Elem(), seen)
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchemaSeen(t.Elem(), seen)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchemaSeen(t.Elem(), seen)}
+	case reflect.Struct:
+		if seen[t] {
+			// recursive type, don't expand again
+			return map[string]interface{}{"type": "object"}
+		}
+		seen[t] = true
+		defer delete(seen, t)
+
+		properties := map[string]interface{}{}
+		addStructFields(t, properties, seen)
+		return map[string]interface{}{"type": "object", "properties": properties}
+	}
+	// interfaces and others can be anything
+	return map[string]interface{}{}
+}
+
+// addStructFields adds the properties of t to properties, flattening
+// embedded structs like encoding/json does.
+func addStructFields(t reflect.Type, properties map[string]interface{}, seen map[reflect.Type]bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				addStructFields(ft, properties, seen)
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			// unexported
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		properties[name] = typeSchemaSeen(f.Type, seen)
+	}
+}
@@ -0,0 +1,192 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	updateLegStatusSchema = `
+{
+	"$id": "PreciousCargoShippping:updateLegStatusSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"description": "ID of Shipment"
+		},
+		"leg": {
+			"type": "integer",
+			"description": "index of leg, starting at 0",
+			"minimum": 0
+		},
+		"status": {
+			"type": "string",
+			"enum": [ "departed", "arrived" ]
+		},
+		"at": {
+			"type": "string",
+			"description": "time of departure or arrival in RFC3339, e.g. 2006-01-02T15:04:05Z"
+		}
+	},
+	"required": [ "id", "leg", "status", "at" ]
+}
+`
+	updateLegStatusSchemaLoader = gojsonschema.NewStringLoader(updateLegStatusSchema)
+)
+
+// Sets a leg of a shipment to departed or arrived. Only the carrier of
+// the leg may do so.
+type updateLegStatusArg struct {
+	ID     string `json:"id"`
+	Leg    int    `json:"leg"`
+	Status string `json:"status"`
+	At     string `json:"at"`
+}
+
+// Emitted when a leg changed its status
+type legStatusEvent struct {
+	ShipmentID string    `json:"shipmentId"`
+	Leg        int       `json:"leg"`
+	CarrierID  string    `json:"carrier"`
+	Status     string    `json:"status"`
+	At         time.Time `json:"at"`
+
+	ShipmentStatus string `json:"shipmentStatus"`
+}
+
+type updateLegStatusInvocation struct {
+	eventRecorder
+
+	arg updateLegStatusArg
+
+	at       time.Time
+	shipment *Shipment
+
+	// returns the updated shipment
+	res Shipment
+}
+
+func (inv *updateLegStatusInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateLegStatusInvocation.checkParseArguments")
+
+	inv.arg = updateLegStatusArg{}
+	err := parseArgument(stub, updateLegStatusSchemaLoader, &inv.arg)
+	if err != nil {
+		return err
+	}
+
+	inv.at, err = time.Parse(time.RFC3339, inv.arg.At)
+	if err != nil {
+		return errors.New("invalid at argument: Not parseable, please provide in RFC3339, e.g. 2006-01-02T15:04:05Z")
+	}
+	inv.at = inv.at.UTC()
+
+	_, x, err := shipmentRegistry().get(stub, inv.arg.ID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate shipment for this ID")
+	}
+	inv.shipment = x.(*Shipment)
+	inv.shipment.ensureLegs()
+	if err = inv.shipment.checkActive(); err != nil {
+		return err
+	}
+
+	if inv.arg.Leg >= len(inv.shipment.Legs) {
+		return fmt.Errorf("invalid leg argument: Shipment has %d legs", len(inv.shipment.Legs))
+	}
+	leg := inv.shipment.Legs[inv.arg.Leg]
+
+	// only the carrier of this leg
+	_, x, err = shipmentCoRegistry().get(stub, leg.CarrierID)
+	if err != nil {
+		loggerFor(stub).Warn(err)
+		return errors.New("unable to locate carrier of leg")
+	}
+	if err = checkCallerIs(stub, x.(*ShipmentCo).Participant); err != nil {
+		return err
+	}
+
+	// legs are handled one after another
+	switch inv.arg.Status {
+	case LegDeparted:
+		if leg.Status != LegPlanned {
+			return errors.New("invalid status argument: Leg has departed already")
+		}
+		if inv.arg.Leg > 0 {
+			prev := inv.shipment.Legs[inv.arg.Leg-1]
+			if prev.Status != LegArrived {
+				return errors.New("invalid status argument: Previous leg has not arrived yet")
+			}
+			if inv.at.Before(*prev.ActualArrival) {
+				return errors.New("invalid at argument: Must not be before arrival of previous leg")
+			}
+		}
+	case LegArrived:
+		if leg.Status != LegDeparted {
+			return errors.New("invalid status argument: Leg has not departed or has arrived already")
+		}
+		if inv.at.Before(*leg.ActualDeparture) {
+			return errors.New("invalid at argument: Must not be before departure")
+		}
+	}
+
+	return nil
+}
+
+func (inv *updateLegStatusInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateLegStatusInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	leg := &inv.shipment.Legs[inv.arg.Leg]
+	leg.Status = inv.arg.Status
+	switch inv.arg.Status {
+	case LegDeparted:
+		leg.ActualDeparture = &inv.at
+	case LegArrived:
+		leg.ActualArrival = &inv.at
+	}
+
+	// a handover, compare seals with the previous one
+	flag, err := recordSealsAtHandover(stub, inv.shipment, inv.arg.Leg, inv.at)
+	if err != nil {
+		return err
+	}
+	if flag != nil {
+		inv.emit("ShipmentFlagged", shipmentFlagEvent{ShipmentID: inv.shipment.ID.ID, Flag: *flag})
+	}
+
+	inv.shipment.deriveStatus()
+
+	_, err = saveShipment(stub, &inv.eventRecorder, inv.shipment)
+	if err != nil {
+		return err
+	}
+	// delivering a consolidated shipment delivers its contents
+	if err = releaseChildren(stub, &inv.eventRecorder, inv.shipment); err != nil {
+		return err
+	}
+
+	inv.emit("LegStatus", legStatusEvent{
+		ShipmentID:     inv.shipment.ID.ID,
+		Leg:            inv.arg.Leg,
+		CarrierID:      leg.CarrierID,
+		Status:         leg.Status,
+		At:             inv.at,
+		ShipmentStatus: inv.shipment.Status,
+	})
+	inv.res = *inv.shipment
+
+	return nil
+}
+
+func (inv *updateLegStatusInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
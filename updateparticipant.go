@@ -0,0 +1,137 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	updateIndividualParticipantSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:updateIndividualParticipantSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"name": {
+			"type": "string",
+			"minLength": 3,
+			"maxLength": 100
+		},
+		"address": %s,
+		"allowDuplicate": {
+			"type": "boolean",
+			"description": "admins only, update even if the result seems to be registered already"
+		}
+	},
+	"required": [ "id" ],
+	"anyOf": [
+		{ "required": [ "name" ] },
+		{ "required": [ "address" ] }
+	]
+}
+`, addressSchema)
+	updateIndividualParticipantSchemaLoader = gojsonschema.NewStringLoader(updateIndividualParticipantSchema)
+)
+
+// Changes name and/or address of an IndividualParticipant
+type updateIndividualParticipantArg struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name" pii:"true"`
+	Address        *Address `json:"address" pii:"true"`
+	AllowDuplicate bool     `json:"allowDuplicate"`
+}
+
+// Returns the updated participant
+type updateIndividualParticipantResult struct {
+	Participant *IndividualParticipant `json:"participant"`
+}
+
+type updateIndividualParticipantInvocation struct {
+	eventRecorder
+
+	arg updateIndividualParticipantArg
+	res updateIndividualParticipantResult
+
+	participant *IndividualParticipant
+}
+
+func (inv *updateIndividualParticipantInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateIndividualParticipantInvocation.checkParseArguments")
+
+	inv.arg = updateIndividualParticipantArg{}
+	if err := parseArgument(stub, updateIndividualParticipantSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+
+	_, x, err := individualParticipantRegistry().get(stub, inv.arg.ID)
+	if err == errNotFound {
+		return errors.New("invalid id argument: Not found")
+	}
+	if err != nil {
+		return err
+	}
+	inv.participant = x.(*IndividualParticipant)
+
+	if err = checkCallerIs(stub, inv.participant.Participant); err != nil {
+		return err
+	}
+	if !inv.participant.active() {
+		return errors.New("invalid id argument: Deactivated")
+	}
+	if inv.arg.AllowDuplicate && !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+
+	return nil
+}
+
+// Updates the participant in place, so that former versions remain
+// in the ledger's history of its key.
+func (inv *updateIndividualParticipantInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateIndividualParticipantInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	p := inv.participant
+	if err := unindexParticipant(stub, KindIndividualParticipant, p); err != nil {
+		return err
+	}
+	if inv.arg.Name != "" {
+		p.Name = inv.arg.Name
+	}
+	if inv.arg.Address != nil {
+		p.Address = *inv.arg.Address
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	p.UpdatedAt = &now
+
+	if _, err = checkDuplicateParticipants(stub, KindIndividualParticipant, p, inv.arg.AllowDuplicate); err != nil {
+		return err
+	}
+	if err = indexParticipant(stub, KindIndividualParticipant, p); err != nil {
+		return err
+	}
+	if err = individualParticipantRegistry().update(stub, p.ID.ID, p); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: KindIndividualParticipant, Participant: p})
+	inv.res = updateIndividualParticipantResult{
+		Participant: p,
+	}
+
+	return nil
+}
+
+func (inv *updateIndividualParticipantInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -0,0 +1,137 @@
+// fabric-precious-cargo-shipping-cc is a sample chaincode for Hyperledger Fabric
+// Copyright (C) 2019 @aschmidt75
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	updateShipmentCoSchema = fmt.Sprintf(`
+{
+	"$id": "PreciousCargoShippping:updateShipmentCoSchema",
+	"type": "object",
+	"properties": {
+		"id": {
+			"type": "string",
+			"pattern": "^([0-9]{4,32})$"
+		},
+		"name": {
+			"type": "string",
+			"minLength": 3,
+			"maxLength": 100
+		},
+		"address": %s,
+		"allowDuplicate": {
+			"type": "boolean",
+			"description": "admins only, update even if the result seems to be registered already"
+		}
+	},
+	"required": [ "id" ],
+	"anyOf": [
+		{ "required": [ "name" ] },
+		{ "required": [ "address" ] }
+	]
+}
+`, addressSchema)
+	updateShipmentCoSchemaLoader = gojsonschema.NewStringLoader(updateShipmentCoSchema)
+)
+
+// Changes name and/or address of a ShipmentCo
+type updateShipmentCoArg struct {
+	ID             string   `json:"id"`
+	Name           string   `json:"name"`
+	Address        *Address `json:"address"`
+	AllowDuplicate bool     `json:"allowDuplicate"`
+}
+
+// Returns the updated participant
+type updateShipmentCoResult struct {
+	Participant *ShipmentCo `json:"participant"`
+}
+
+type updateShipmentCoInvocation struct {
+	eventRecorder
+
+	arg updateShipmentCoArg
+	res updateShipmentCoResult
+
+	participant *ShipmentCo
+}
+
+func (inv *updateShipmentCoInvocation) checkParseArguments(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateShipmentCoInvocation.checkParseArguments")
+
+	inv.arg = updateShipmentCoArg{}
+	if err := parseArgument(stub, updateShipmentCoSchemaLoader, &inv.arg); err != nil {
+		return err
+	}
+
+	_, x, err := shipmentCoRegistry().get(stub, inv.arg.ID)
+	if err == errNotFound {
+		return errors.New("invalid id argument: Not found")
+	}
+	if err != nil {
+		return err
+	}
+	inv.participant = x.(*ShipmentCo)
+
+	if err = checkCallerIs(stub, inv.participant.Participant); err != nil {
+		return err
+	}
+	if !inv.participant.active() {
+		return errors.New("invalid id argument: Deactivated")
+	}
+	if inv.arg.AllowDuplicate && !callerIsAdmin(stub) {
+		return errNotAuthorized
+	}
+
+	return nil
+}
+
+// Updates the participant in place, so that former versions remain
+// in the ledger's history of its key.
+func (inv *updateShipmentCoInvocation) process(stub shim.ChaincodeStubInterface) error {
+	loggerFor(stub).Debug("enter updateShipmentCoInvocation.process")
+	loggerFor(stub).Debugf("arg=%v", inv.arg)
+
+	p := inv.participant
+	if err := unindexParticipant(stub, KindShipmentCo, p); err != nil {
+		return err
+	}
+	if inv.arg.Name != "" {
+		p.Name = inv.arg.Name
+	}
+	if inv.arg.Address != nil {
+		p.Address = *inv.arg.Address
+	}
+	now, err := txTime(stub)
+	if err != nil {
+		return err
+	}
+	p.UpdatedAt = &now
+
+	if _, err = checkDuplicateParticipants(stub, KindShipmentCo, p, inv.arg.AllowDuplicate); err != nil {
+		return err
+	}
+	if err = indexParticipant(stub, KindShipmentCo, p); err != nil {
+		return err
+	}
+	if err = shipmentCoRegistry().update(stub, p.ID.ID, p); err != nil {
+		return err
+	}
+	inv.emit("ParticipantSaved", participantSavedEvent{Kind: KindShipmentCo, Participant: p})
+	inv.res = updateShipmentCoResult{
+		Participant: p,
+	}
+
+	return nil
+}
+
+func (inv *updateShipmentCoInvocation) getResponse(stub shim.ChaincodeStubInterface) interface{} {
+	return inv.res
+}
@@ -7,10 +7,16 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/hyperledger/fabric/core/chaincode/shim"
+	"github.com/xeipuuv/gojsonschema"
 )
 
+// errNotFound is returned by registries if no item exists for a key
+var errNotFound = errors.New("not found")
+
 // InvocationHandler is a generic interface for wrapping a
 // single chaincode transaction.
 type InvocationHandler interface {
@@ -31,94 +37,118 @@ type InvocationHandler interface {
 	getResponse(stub shim.ChaincodeStubInterface) interface{}
 }
 
-func newID(stub shim.ChaincodeStubInterface, indexName string) (string, error) {
-	ckIndex, err := stub.CreateCompositeKey(ns, []string{".", indexName, ".", "index"})
-	if err != nil {
-		return "", err
+// parseArgument expects a single JSON call argument, validates it
+// against the given schema and unmarshals it into v.
+func parseArgument(stub shim.ChaincodeStubInterface, schemaLoader gojsonschema.JSONLoader, v interface{}) error {
+	_, args := stub.GetFunctionAndParameters()
+
+	if len(args) != 1 {
+		return errors.New("expecting JSON input as first param")
 	}
 
-	var lastIndex uint64
-	lastIndexBytes, err := stub.GetState(ckIndex)
+	result, err := gojsonschema.Validate(schemaLoader, gojsonschema.NewStringLoader(args[0]))
 	if err != nil {
-		return "", err
+		loggerFor(stub).Warn(err)
+		return errors.New("error parsing/validating JSON arg")
 	}
-	if lastIndexBytes != nil {
-		lastIndex, err = strconv.ParseUint(string(lastIndexBytes), 10, 64)
-		if err != nil {
-			return "", err
+	if !result.Valid() {
+		loggerFor(stub).Debugf("JSON input not valid")
+		for _, err := range result.Errors() {
+			loggerFor(stub).Debugf("- %s", err)
 		}
+		return errors.New("json not valid according to schema")
 	}
-	lastIndex = lastIndex + 1
 
-	err = stub.PutState(ckIndex, []byte(strconv.FormatUint(lastIndex, 10)))
+	err = json.Unmarshal([]byte(args[0]), v)
 	if err != nil {
-		return "", err
+		loggerFor(stub).Debugf("error unmarshaling JSON: %s", err)
+		return errors.New("Invalid JSON")
 	}
-	logger.Printf("newId: PutState to key=%s, index=%v\n", ckIndex, lastIndex)
-
-	return fmt.Sprintf("%010v", lastIndex), nil
-
+	return nil
 }
 
-func getGenericKey(stub shim.ChaincodeStubInterface, typeStr string, id string) (string, error) {
-	ck, err := stub.CreateCompositeKey(ns, []string{".", typeStr, "#", id})
+// txTime returns the transaction timestamp. Unlike time.Now() it is
+// the same on all endorsing peers.
+func txTime(stub shim.ChaincodeStubInterface) (time.Time, error) {
+	ts, err := stub.GetTxTimestamp()
 	if err != nil {
-		logger.Println(err)
-		return "", err
+		loggerFor(stub).Error(err)
+		return time.Time{}, errors.New("internal error reading transaction timestamp")
 	}
-	return ck, nil
+	return time.Unix(ts.Seconds, int64(ts.Nanos)).UTC(), nil
 }
 
-func getIndividualParticipantKey(stub shim.ChaincodeStubInterface, id string) (string, error) {
-	return getGenericKey(stub, "IndividualParticipant", id)
-}
-
-func getShipmentCoKey(stub shim.ChaincodeStubInterface, id string) (string, error) {
-	return getGenericKey(stub, "ShipmentCo", id)
+// validateJSON validates a JSON document against a schema. Unlike
+// parseArgument, the error lists all violations, for reporting them
+// per item of a batch.
+func validateJSON(schemaLoader gojsonschema.JSONLoader, data []byte) error {
+	result, err := gojsonschema.Validate(schemaLoader, gojsonschema.NewBytesLoader(data))
+	if err != nil {
+		logger.Warn(err)
+		return errors.New("error parsing/validating JSON")
+	}
+	if !result.Valid() {
+		msgs := []string{}
+		for _, e := range result.Errors() {
+			msgs = append(msgs, e.String())
+		}
+		return fmt.Errorf("json not valid according to schema: %s", strings.Join(msgs, "; "))
+	}
+	return nil
 }
 
-func getShipmentKey(stub shim.ChaincodeStubInterface, id string) (string, error) {
-	return getGenericKey(stub, "Shipment", id)
+func newID(stub shim.ChaincodeStubInterface, indexName string) (string, error) {
+	ids, err := newIDs(stub, indexName, 1)
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
 }
 
-func getIndividualParticipant(stub shim.ChaincodeStubInterface, id string) (string, *IndividualParticipant, error) {
-	ck, err := getIndividualParticipantKey(stub, id)
+// newIDs returns the next n IDs of an index. A transaction does not see its
+// own writes, so all IDs of an index it needs have to be taken at once.
+func newIDs(stub shim.ChaincodeStubInterface, indexName string, n int) ([]string, error) {
+	ckIndex, err := stub.CreateCompositeKey(ns, []string{".", indexName, ".", "index"})
 	if err != nil {
-		return "", nil, err
+		return nil, err
 	}
-	data, err := stub.GetState(ck)
+
+	var lastIndex uint64
+	lastIndexBytes, err := stub.GetState(ckIndex)
 	if err != nil {
-		logger.Println(err)
-		return "", nil, errors.New("internal error reading from world state (1)")
+		return nil, err
 	}
-	res := &IndividualParticipant{}
-	err = json.Unmarshal(data, res)
+	if lastIndexBytes != nil {
+		lastIndex, err = strconv.ParseUint(string(lastIndexBytes), 10, 64)
+		if err != nil {
+			return nil, err
+		}
+	}
+	ids := []string{}
+	for i := 0; i < n; i++ {
+		lastIndex = lastIndex + 1
+		ids = append(ids, fmt.Sprintf("%010v", lastIndex))
+	}
+
+	err = stub.PutState(ckIndex, []byte(strconv.FormatUint(lastIndex, 10)))
 	if err != nil {
-		logger.Println(err)
-		return "", nil, errors.New("internal error reading from world state (2)")
+		return nil, err
 	}
-	logger.Printf("Found %#v\n", res)
+	loggerFor(stub).Debugf("newIDs: PutState to key=%s, index=%v", ckIndex, lastIndex)
 
-	return ck, res, nil
+	return ids, nil
 }
 
-func getShipmentCo(stub shim.ChaincodeStubInterface, id string) (string, *ShipmentCo, error) {
-	ck, err := getShipmentCoKey(stub, id)
+// getActiveDevice loads a device and makes sure it has not been revoked
+func getActiveDevice(stub shim.ChaincodeStubInterface, id string) (*Device, error) {
+	_, x, err := deviceRegistry().get(stub, id)
 	if err != nil {
-		return "", nil, err
+		loggerFor(stub).Warn(err)
+		return nil, fmt.Errorf("device %s: Not found", id)
 	}
-	data, err := stub.GetState(ck)
-	if err != nil {
-		logger.Println(err)
-		return "", nil, errors.New("internal error reading from world state (1)")
+	d := x.(*Device)
+	if d.Status != DeviceActive {
+		return nil, fmt.Errorf("device %s: Not active", id)
 	}
-	res := &ShipmentCo{}
-	err = json.Unmarshal(data, res)
-	if err != nil {
-		logger.Println(err)
-		return "", nil, errors.New("internal error reading from world state (2)")
-	}
-	logger.Printf("Found %#v\n", res)
-
-	return ck, res, nil
+	return d, nil
 }